@@ -0,0 +1,149 @@
+package photofunia
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//go:embed catalog.json
+var catalogJSON []byte
+
+// CatalogEntry describes a known PhotoFunia effect in a Catalog.
+type CatalogEntry struct {
+	// Path is the category path of the effect, for example "faces/fat_maker".
+	Path string `json:"path"`
+
+	// Title is the display title of the effect.
+	Title string `json:"title"`
+
+	// Description is a short description of what the effect does.
+	Description string `json:"description,omitempty"`
+
+	// Tags lists keywords describing the effect.
+	Tags []string `json:"tags,omitempty"`
+
+	// Schema describes the form fields of the effect.
+	Schema Schema `json:"schema"`
+}
+
+// Category returns the category segment of the entry path,
+// for example "faces" for "faces/fat_maker".
+func (e CatalogEntry) Category() string {
+	return Effect{Path: e.Path}.Category()
+}
+
+// Effect returns an Effect that can be passed to ApplyEffect.
+// The effect uses the schema defaults and inputs and validates
+// parameters against the schema.
+func (e CatalogEntry) Effect() Effect {
+	schema := e.Schema
+	effect := schema.effect(e.Title)
+	effect.Path = e.Path
+	return effect
+}
+
+// Catalog is a versioned snapshot of known PhotoFunia effects.
+type Catalog struct {
+	// Version is incremented every time the snapshot is refreshed.
+	Version int `json:"version"`
+
+	// Updated is the date the snapshot was taken, formatted as YYYY-MM-DD.
+	Updated string `json:"updated"`
+
+	// Effects lists the effects of the snapshot, sorted by path.
+	Effects []CatalogEntry `json:"effects"`
+}
+
+var (
+	defaultCatalog     *Catalog
+	defaultCatalogOnce sync.Once
+)
+
+// DefaultCatalog returns the effect catalog snapshot embedded in the package.
+// Looking up effects in it does not make any network request.
+//
+// The returned catalog is shared and must not be modified.
+func DefaultCatalog() *Catalog {
+	defaultCatalogOnce.Do(func() {
+		catalog, err := ParseCatalog(catalogJSON)
+		if err != nil {
+			panic("photofunia: invalid embedded catalog: " + err.Error())
+		}
+		defaultCatalog = catalog
+	})
+	return defaultCatalog
+}
+
+// ParseCatalog decodes a catalog from its JSON representation.
+func ParseCatalog(data []byte) (*Catalog, error) {
+	var catalog Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to decode catalog: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range catalog.Effects {
+		if entry.Path == "" {
+			return nil, errors.New("catalog entry has no path")
+		}
+		if seen[entry.Path] {
+			return nil, fmt.Errorf("catalog entry %s is listed twice", entry.Path)
+		}
+		seen[entry.Path] = true
+	}
+
+	return &catalog, nil
+}
+
+// ByPath returns the entry with the given category path.
+func (c *Catalog) ByPath(path string) (CatalogEntry, bool) {
+	for _, entry := range c.Effects {
+		if entry.Path == path {
+			return entry, true
+		}
+	}
+	return CatalogEntry{}, false
+}
+
+// ByCategory returns the entries of the given category, such as "faces".
+func (c *Catalog) ByCategory(category string) []CatalogEntry {
+	var entries []CatalogEntry
+	for _, entry := range c.Effects {
+		if entry.Category() == category {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Search returns the entries matching every word of the query.
+// Words are matched case-insensitively against the path, title,
+// description and tags of each entry.
+func (c *Catalog) Search(query string) []CatalogEntry {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	var entries []CatalogEntry
+	for _, entry := range c.Effects {
+		text := strings.ToLower(strings.Join(append([]string{entry.Path, entry.Title, entry.Description}, entry.Tags...), " "))
+
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,107 @@
+package photofunia
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDefaultCatalog(t *testing.T) {
+	catalog := DefaultCatalog()
+
+	if catalog.Version < 1 {
+		t.Errorf("Version = %d, want at least 1", catalog.Version)
+	}
+
+	if !sort.SliceIsSorted(catalog.Effects, func(i, j int) bool {
+		return catalog.Effects[i].Path < catalog.Effects[j].Path
+	}) {
+		t.Error("catalog effects are not sorted by path")
+	}
+
+	for _, entry := range catalog.Effects {
+		if entry.Schema.Path != entry.Path {
+			t.Errorf("entry %s has schema for %s", entry.Path, entry.Schema.Path)
+		}
+		if entry.Title == "" {
+			t.Errorf("entry %s has no title", entry.Path)
+		}
+
+		// Effects taking required text have no complete set of defaults.
+		if len(entry.Schema.ImageInputs()) == 0 {
+			continue
+		}
+
+		effect := entry.Effect()
+		if err := effect.Schema.Validate(effect.params(nil)); err != nil {
+			t.Errorf("defaults of entry %s are invalid: %v", entry.Path, err)
+		}
+	}
+}
+
+func TestDefaultCatalogCoversFacesEffects(t *testing.T) {
+	catalog := DefaultCatalog()
+
+	for _, effect := range FacesEffects() {
+		entry, ok := catalog.ByPath(effect.Path)
+		if !ok {
+			t.Errorf("effect %s is missing from the catalog", effect.Path)
+			continue
+		}
+
+		if !reflect.DeepEqual(entry.Effect().Inputs, effect.Inputs) {
+			t.Errorf("effect %s inputs = %+v, catalog has %+v", effect.Path, effect.Inputs, entry.Effect().Inputs)
+		}
+		if err := entry.Schema.Validate(effect.params(nil)); err != nil {
+			t.Errorf("effect %s params do not match the catalog schema: %v", effect.Path, err)
+		}
+	}
+}
+
+func TestCatalogLookups(t *testing.T) {
+	catalog := DefaultCatalog()
+
+	if _, ok := catalog.ByPath("faces/does_not_exist"); ok {
+		t.Error("ByPath() found an unknown effect")
+	}
+
+	if got := len(catalog.ByCategory("faces")); got != len(FacesEffects()) {
+		t.Errorf("ByCategory(faces) returned %d entries, want %d", got, len(FacesEffects()))
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "clown", want: []string{"faces/clown"}},
+		{query: "HALLOWEEN", want: []string{"faces/vampire", "faces/zombie"}},
+		{query: "text beach", want: []string{"lab/writing_on_sand"}},
+		{query: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, entry := range catalog.Search(tt.query) {
+			got = append(got, entry.Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseCatalogInvalid(t *testing.T) {
+	tests := map[string]string{
+		"Invalid JSON":   `{`,
+		"Missing path":   `{"version":1,"effects":[{"title":"x"}]}`,
+		"Duplicate path": `{"version":1,"effects":[{"path":"a/b"},{"path":"a/b"}]}`,
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseCatalog([]byte(data)); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package photofunia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Checkpoint records the progress of applying an effect, so that a failed run
+// can be resumed from the stage that failed with Resume instead of starting
+// over. Errors of a failed run carry one in their Error.Checkpoint field.
+//
+// A Checkpoint can be encoded with encoding/json and resumed in a later
+// process, as long as the PhotoFunia session and uploaded images have not
+// expired. It holds the PHPSESSID of the run, and should be stored as
+// carefully as the session itself.
+type Checkpoint struct {
+	// Effect is the name of the effect used in log messages.
+	Effect string `json:"effect"`
+
+	// Path is the category path of the effect, for example "faces/fat_maker".
+	Path string `json:"path"`
+
+	// SessionID is the PHPSESSID of the run. It is updated when the run
+	// replaces an expired session.
+	SessionID string `json:"sessionId,omitempty"`
+
+	// Params holds the form parameters of the effect, without the image keys.
+	Params map[string]string `json:"params,omitempty"`
+
+	// ImageFields lists the image inputs of the effect, in order.
+	ImageFields []string `json:"imageFields,omitempty"`
+
+	// ImageKeys maps the image inputs uploaded so far to their image keys.
+	ImageKeys map[string]string `json:"imageKeys,omitempty"`
+
+	// ResultURL is the URL of the result page, once the effect form was posted.
+	ResultURL string `json:"resultUrl,omitempty"`
+
+	// ImageURL is the URL of the result image, once the result page was read.
+	ImageURL string `json:"imageUrl,omitempty"`
+}
+
+// newCheckpoint returns the checkpoint of a run that has not started yet.
+// Keys of images uploaded earlier are moved from params to ImageKeys.
+func newCheckpoint(effectPath string, params map[string]string, imageFields []string, effectName string) *Checkpoint {
+	cp := &Checkpoint{
+		Effect:      effectName,
+		Path:        effectPath,
+		Params:      make(map[string]string, len(params)),
+		ImageFields: imageFields,
+		ImageKeys:   make(map[string]string, len(imageFields)),
+	}
+	for key, value := range params {
+		if containsString(imageFields, key) {
+			if value != "" {
+				cp.ImageKeys[key] = value
+			}
+			continue
+		}
+		cp.Params[key] = value
+	}
+	return cp
+}
+
+// Stage returns the stage a run resumed from the checkpoint starts with.
+func (cp *Checkpoint) Stage() Stage {
+	switch {
+	case cp.ImageURL != "":
+		return StageDownload
+	case cp.ResultURL != "":
+		return StageResultPage
+	case cp.SessionID == "":
+		return StageSession
+	case len(cp.pendingImages()) > 0:
+		return StageUpload
+	default:
+		return StageApply
+	}
+}
+
+// pendingImages returns the image inputs that still have to be uploaded.
+func (cp *Checkpoint) pendingImages() []string {
+	if cp.ResultURL != "" {
+		return nil
+	}
+
+	var pending []string
+	for _, field := range cp.ImageFields {
+		if cp.ImageKeys[field] == "" {
+			pending = append(pending, field)
+		}
+	}
+	return pending
+}
+
+// formParams returns the form parameters to post, including the image keys.
+func (cp *Checkpoint) formParams() map[string]string {
+	params := make(map[string]string, len(cp.Params)+len(cp.ImageKeys))
+	for key, value := range cp.Params {
+		params[key] = value
+	}
+	for field, key := range cp.ImageKeys {
+		params[field] = key
+	}
+	return params
+}
+
+// clone returns a copy of the checkpoint that does not share its maps.
+func (cp *Checkpoint) clone() *Checkpoint {
+	clone := *cp
+	clone.Params = make(map[string]string, len(cp.Params))
+	for key, value := range cp.Params {
+		clone.Params[key] = value
+	}
+	clone.ImageFields = append([]string(nil), cp.ImageFields...)
+	clone.ImageKeys = make(map[string]string, len(cp.ImageKeys))
+	for field, key := range cp.ImageKeys {
+		clone.ImageKeys[field] = key
+	}
+	return &clone
+}
+
+// fail sets the effect name and a snapshot of the checkpoint on the Error
+// in err, if any.
+func (cp *Checkpoint) fail(err error) error {
+	var stageErr *Error
+	if errors.As(err, &stageErr) && stageErr.Checkpoint == nil {
+		stageErr.Checkpoint = cp.clone()
+	}
+	return withEffect(err, cp.Effect)
+}
+
+// validate checks that images holds exactly the image inputs that still
+// have to be uploaded.
+func (cp *Checkpoint) validate(images map[string]io.ReadCloser) error {
+	if cp.Path == "" {
+		return errors.New("checkpoint effect path is empty")
+	}
+
+	pending := cp.pendingImages()
+	for _, field := range pending {
+		if images[field] == nil {
+			return fmt.Errorf("checkpoint of effect %s requires image input %s", cp.Path, field)
+		}
+	}
+	for field := range images {
+		if !containsString(pending, field) {
+			return fmt.Errorf("image input %s of effect %s is not pending in the checkpoint", field, cp.Path)
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Resume continues a failed run from its checkpoint, skipping the stages that
+// already succeeded, and returns the processed image along with metadata
+// about the resumed stages. The checkpoint is usually taken from the
+// Checkpoint field of the Error returned by the failed run, and is not modified.
+//
+// Requests are made with the PHPSESSID of the checkpoint. The images
+// parameter supplies the image inputs that were not uploaded before the run
+// failed, and must be nil when the checkpoint is past the upload stage.
+// The readers are closed when done.
+func (c *PhotoFuniaClient) Resume(ctx context.Context, checkpoint *Checkpoint, images map[string]io.ReadCloser) (*Result, error) {
+	if err := checkpoint.validate(images); err != nil {
+		for _, img := range images {
+			if img != nil {
+				img.Close()
+			}
+		}
+		return nil, err
+	}
+
+	cp := checkpoint.clone()
+	if cp.SessionID != "" && cp.SessionID != c.SessionID() {
+		c = c.With(withSessionID(cp.SessionID))
+	}
+
+	c.logger.Info("resuming effect", Field{"effect", cp.Effect}, Field{"stage", string(cp.Stage())})
+	return c.resumeWithContext(ctx, cp, images)
+}
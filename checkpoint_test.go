@@ -0,0 +1,318 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countRequests wraps the transport, counting requests by the first of the
+// given URL substrings they contain.
+func countRequests(transport *MockTransport, matches ...string) func(match string) int {
+	var mu sync.Mutex
+	counts := make(map[string]int)
+
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		for _, match := range matches {
+			if strings.Contains(req.URL.String(), match) {
+				mu.Lock()
+				counts[match]++
+				mu.Unlock()
+				break
+			}
+		}
+		return roundTrip(req)
+	}
+
+	return func(match string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[match]
+	}
+}
+
+func checkpointOf(t *testing.T, err error) *Checkpoint {
+	t.Helper()
+
+	var stageErr *Error
+	if !errors.As(err, &stageErr) {
+		t.Fatalf("error = %v, want *Error", err)
+	}
+	if stageErr.Checkpoint == nil {
+		t.Fatalf("error = %v has no checkpoint", err)
+	}
+	return stageErr.Checkpoint
+}
+
+func TestResumeAfterDownloadFailure(t *testing.T) {
+	transport, _ := failingTransport(t, "GET", "example.com/result.jpg", 1, statusResponse(http.StatusBadGateway, nil))
+	requests := countRequests(transport, "cookie-warning", "/images", "/categories/", "/results/", "result.jpg")
+
+	client := NewClient(WithTransport(transport))
+	_, err := client.ApplyEffectResult(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if err == nil {
+		t.Fatal("ApplyEffectResult() succeeded, want download error")
+	}
+
+	cp := checkpointOf(t, err)
+	if got := cp.Stage(); got != StageDownload {
+		t.Errorf("Stage() = %q, want %q", got, StageDownload)
+	}
+	if cp.ImageKeys["image"] != "test-image-key" || cp.ImageURL != "https://example.com/result.jpg" {
+		t.Errorf("checkpoint = %+v, want image key and image URL", cp)
+	}
+
+	// Resume from the encoded checkpoint in a new client, as a later process would.
+	encoded, err := json.Marshal(cp)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var decoded Checkpoint
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	result, err := NewClient(WithTransport(transport)).Resume(context.Background(), &decoded, nil)
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if string(result.Data) != "fake-image-data" {
+		t.Errorf("Data = %q, want %q", result.Data, "fake-image-data")
+	}
+	if result.ImageKey != "test-image-key" || result.ResultURL != cp.ResultURL {
+		t.Errorf("Result = %+v, want the keys and URLs of the checkpoint", result)
+	}
+	if result.Timings.Upload != 0 || result.Timings.Apply != 0 {
+		t.Errorf("Timings = %+v, want skipped stages to be zero", result.Timings)
+	}
+
+	for match, want := range map[string]int{"cookie-warning": 1, "/images": 1, "/categories/": 1, "/results/": 1, "result.jpg": 2} {
+		if got := requests(match); got != want {
+			t.Errorf("requests to %s = %d, want %d", match, got, want)
+		}
+	}
+}
+
+func TestCheckpointRecordsRefreshedSession(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		match  string
+	}{
+		{name: "Apply redirected to cookie warning", method: "POST", match: "/categories/"},
+		{name: "Result page redirected to cookie warning", method: "GET", match: "/results/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, sessions := sessionTransport(t, "")
+
+			var mu sync.Mutex
+			downloads := 0
+			roundTrip := transport.RoundTripFunc
+			transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+				switch {
+				case req.Method == tt.method && strings.Contains(req.URL.String(), tt.match) &&
+					strings.HasSuffix(req.Header.Get("Cookie"), "PHPSESSID=session-1"):
+					if req.Body != nil {
+						io.Copy(io.Discard, req.Body)
+					}
+					return cookieWarningResponse()
+				case strings.Contains(req.URL.String(), "result.jpg"):
+					mu.Lock()
+					downloads++
+					failing := downloads == 1
+					mu.Unlock()
+					if failing {
+						return statusResponse(http.StatusBadGateway, nil)()
+					}
+				}
+				return roundTrip(req)
+			}
+
+			client := NewClient(WithTransport(transport))
+			_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+			if err == nil {
+				t.Fatal("ApplyEffect() succeeded, want download error")
+			}
+
+			cp := checkpointOf(t, err)
+			if cp.SessionID != "session-2" || client.SessionID() != "session-2" {
+				t.Errorf("checkpoint SessionID = %q, client SessionID() = %q, want both %q", cp.SessionID, client.SessionID(), "session-2")
+			}
+
+			if _, err := NewClient(WithTransport(transport)).Resume(context.Background(), cp, nil); err != nil {
+				t.Fatalf("Resume() error = %v", err)
+			}
+			if got := sessions.Load(); got != 2 {
+				t.Errorf("session requests = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestResumeAfterApplyFailure(t *testing.T) {
+	transport, _ := failingTransport(t, "POST", "/categories/faces/fat_maker", 1, statusResponse(http.StatusServiceUnavailable, nil))
+
+	var sessions []string
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "/categories/") {
+			sessions = append(sessions, req.Header.Get("Cookie"))
+		}
+		return roundTrip(req)
+	}
+	requests := countRequests(transport, "/images")
+
+	client := NewClient(WithTransport(transport))
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), map[string]string{"size": "XL"})
+	if err == nil {
+		t.Fatal("ApplyEffect() succeeded, want apply error")
+	}
+
+	cp := checkpointOf(t, err)
+	if got := cp.Stage(); got != StageApply {
+		t.Errorf("Stage() = %q, want %q", got, StageApply)
+	}
+
+	resumed := NewClient(WithTransport(transport), WithSessionSource(StaticSession("other-session")))
+	if _, err := resumed.Resume(context.Background(), cp, nil); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if got := requests("/images"); got != 1 {
+		t.Errorf("uploads = %d, want 1", got)
+	}
+	if resumed.SessionID() != "" {
+		t.Errorf("Resume() changed the PHPSESSID of the client to %q", resumed.SessionID())
+	}
+	for _, cookie := range sessions {
+		if !strings.Contains(cookie, "PHPSESSID=test-session-id") {
+			t.Errorf("apply request Cookie = %q, want the session of the checkpoint", cookie)
+		}
+	}
+	if cp.ResultURL != "" {
+		t.Error("Resume() modified the checkpoint")
+	}
+}
+
+func TestResumeAfterUploadFailure(t *testing.T) {
+	transport, _ := failingTransport(t, "POST", "/images", 1, statusResponse(http.StatusServiceUnavailable, nil))
+
+	var form map[string]string
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && strings.Contains(req.URL.String(), "/categories/") {
+			body, _ := io.ReadAll(req.Body)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			form, _ = readMultipartForm(req)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		return roundTrip(req)
+	}
+	client := NewClient(WithTransport(transport))
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if err == nil {
+		t.Fatal("ApplyEffect() succeeded, want upload error")
+	}
+
+	cp := checkpointOf(t, err)
+	if got := cp.Stage(); got != StageUpload {
+		t.Errorf("Stage() = %q, want %q", got, StageUpload)
+	}
+
+	if _, err := client.Resume(context.Background(), cp, nil); err == nil || !strings.Contains(err.Error(), "requires image input image") {
+		t.Errorf("Resume() without the image error = %v, want missing image", err)
+	}
+
+	images := map[string]io.ReadCloser{"image": io.NopCloser(bytes.NewReader([]byte("fake-image-data")))}
+	if _, err := client.Resume(context.Background(), cp, images); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if form["image"] != "test-image-key" {
+		t.Errorf("form image = %q, want %q", form["image"], "test-image-key")
+	}
+}
+
+func TestResumeRejectsUploadedImages(t *testing.T) {
+	cp := &Checkpoint{Path: "faces/fat_maker", SessionID: "session", ImageFields: []string{"image"}, ImageKeys: map[string]string{"image": "key"}}
+	img := &seekableImage{Reader: bytes.NewReader(nil)}
+
+	client := &PhotoFuniaClient{logger: &MockLogger{}, client: &http.Client{Transport: &MockTransport{}}}
+	if _, err := client.Resume(context.Background(), cp, map[string]io.ReadCloser{"image": img}); err == nil {
+		t.Error("Resume() with an uploaded image succeeded, want error")
+	}
+	if !img.closed {
+		t.Error("image was not closed")
+	}
+}
+
+func TestNewCheckpointSeparatesImageKeys(t *testing.T) {
+	params := map[string]string{"size": "XL", "image": "uploaded-key"}
+	cp := newCheckpoint("faces/fat_maker", params, []string{"image"}, "fatify")
+
+	if want := map[string]string{"size": "XL"}; !reflect.DeepEqual(cp.Params, want) {
+		t.Errorf("Params = %v, want %v", cp.Params, want)
+	}
+	if want := map[string]string{"image": "uploaded-key"}; !reflect.DeepEqual(cp.ImageKeys, want) {
+		t.Errorf("ImageKeys = %v, want %v", cp.ImageKeys, want)
+	}
+	if got := cp.formParams(); got["image"] != "uploaded-key" || got["size"] != "XL" {
+		t.Errorf("formParams() = %v, want the image key and size", got)
+	}
+}
+
+func TestCheckpointStage(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkpoint Checkpoint
+		want       Stage
+	}{
+		{
+			name:       "New run",
+			checkpoint: Checkpoint{ImageFields: []string{"image"}},
+			want:       StageSession,
+		},
+		{
+			name:       "Pending upload",
+			checkpoint: Checkpoint{SessionID: "session", ImageFields: []string{"image", "image2"}, ImageKeys: map[string]string{"image": "key"}},
+			want:       StageUpload,
+		},
+		{
+			name:       "Uploaded",
+			checkpoint: Checkpoint{SessionID: "session", ImageFields: []string{"image"}, ImageKeys: map[string]string{"image": "key"}},
+			want:       StageApply,
+		},
+		{
+			name:       "Without images",
+			checkpoint: Checkpoint{SessionID: "session"},
+			want:       StageApply,
+		},
+		{
+			name:       "Applied",
+			checkpoint: Checkpoint{SessionID: "session", ResultURL: "https://photofunia.com/results/1"},
+			want:       StageResultPage,
+		},
+		{
+			name:       "Result page read",
+			checkpoint: Checkpoint{SessionID: "session", ResultURL: "https://photofunia.com/results/1", ImageURL: "https://photofunia.com/downloads/1.jpg"},
+			want:       StageDownload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.checkpoint.Stage(); got != tt.want {
+				t.Errorf("Stage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+// Command photofunia-gen generates typed effect wrappers for the photofunia package.
+//
+// It reads effect schemas saved as JSON, in the format produced by encoding a
+// photofunia.Schema, and emits one option struct and one context-aware method
+// per effect, following the pattern of ClownifyWithContext. The methods apply
+// the effect through ApplyEffect with its schema, so the schema defaults are
+// sent and the options are validated before anything is uploaded.
+//
+// Usage:
+//
+//	photofunia-gen -schemas schemas -out effects_gen.go
+//
+// It is normally run through go generate from the photofunia package directory.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/swiftyspiffy/photofunia"
+)
+
+func main() {
+	schemaDir := flag.String("schemas", "schemas", "directory containing the effect schema JSON files")
+	out := flag.String("out", "effects_gen.go", "output Go file")
+	pkg := flag.String("package", "photofunia", "package name of the generated file")
+	flag.Parse()
+
+	schemas, err := loadSchemas(*schemaDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "photofunia-gen:", err)
+		os.Exit(1)
+	}
+
+	src, err := generate(*pkg, schemas)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "photofunia-gen:", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "photofunia-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// loadSchemas reads every JSON file in dir as a photofunia.Schema,
+// sorted by effect path.
+func loadSchemas(dir string) ([]*photofunia.Schema, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []*photofunia.Schema
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var schema photofunia.Schema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+		if schema.Path == "" {
+			return nil, fmt.Errorf("schema %s has no path", file)
+		}
+
+		schemas = append(schemas, &schema)
+	}
+
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Path < schemas[j].Path })
+	return schemas, nil
+}
+
+type effectData struct {
+	Name    string
+	Var     string
+	Path    string
+	Slug    string
+	Fields  []string
+	Options []optionData
+}
+
+type optionData struct {
+	Field     string
+	Name      string
+	Kind      photofunia.FieldKind
+	Type      string
+	Default   string
+	MaxLength int
+	Values    []constData
+
+	// On and Off are the values sent for a checkbox option.
+	On, Off string
+}
+
+type constData struct {
+	Name  string
+	Value string
+}
+
+// generate renders the Go source of the wrappers for the given schemas.
+func generate(pkg string, schemas []*photofunia.Schema) ([]byte, error) {
+	var effects []effectData
+	for _, schema := range schemas {
+		effect, err := newEffectData(schema)
+		if err != nil {
+			return nil, err
+		}
+		effects = append(effects, effect)
+	}
+
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, struct {
+		Package string
+		Effects []effectData
+	}{pkg, effects}); err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return src, nil
+}
+
+func newEffectData(schema *photofunia.Schema) (effectData, error) {
+	slug := schema.Path[strings.LastIndex(schema.Path, "/")+1:]
+	effect := effectData{
+		Name: goName(slug),
+		Path: schema.Path,
+		Slug: slug,
+	}
+	effect.Var = strings.ToLower(effect.Name[:1]) + effect.Name[1:] + "Schema"
+
+	var imageField string
+	for _, field := range schema.Fields {
+		switch field.Kind {
+		case photofunia.FieldImage:
+			if imageField != "" {
+				return effectData{}, fmt.Errorf("effect %s takes more than one image", schema.Path)
+			}
+			imageField = field.Name
+		case photofunia.FieldHidden:
+		case photofunia.FieldCheckbox:
+			option := optionData{
+				Field: goName(field.Name),
+				Name:  field.Name,
+				Kind:  field.Kind,
+				Type:  "bool",
+				On:    "on",
+				Off:   "off",
+			}
+			if len(field.Values) > 0 {
+				option.On = field.Values[0]
+			}
+			if len(field.Values) > 1 {
+				option.Off = field.Values[1]
+			}
+			effect.Options = append(effect.Options, option)
+		case photofunia.FieldText:
+			effect.Options = append(effect.Options, optionData{
+				Field:     goName(field.Name),
+				Name:      field.Name,
+				Kind:      field.Kind,
+				Type:      "string",
+				Default:   field.Default,
+				MaxLength: field.MaxLength,
+			})
+		case photofunia.FieldSelect:
+			option := optionData{
+				Field:   goName(field.Name),
+				Name:    field.Name,
+				Kind:    field.Kind,
+				Type:    effect.Name + goName(field.Name),
+				Default: field.Default,
+			}
+			seen := make(map[string]bool)
+			for _, value := range field.Values {
+				name := option.Type + goName(value)
+				if value == "" || seen[name] {
+					continue
+				}
+				seen[name] = true
+				option.Values = append(option.Values, constData{name, value})
+			}
+			effect.Options = append(effect.Options, option)
+		default:
+			return effectData{}, fmt.Errorf("effect %s has field %s of unknown kind %q", schema.Path, field.Name, field.Kind)
+		}
+		effect.Fields = append(effect.Fields, fieldLiteral(field))
+	}
+
+	if imageField == "" {
+		return effectData{}, fmt.Errorf("effect %s does not take an image", schema.Path)
+	}
+
+	return effect, nil
+}
+
+// fieldLiteral returns the Go composite literal of a form field, for use in
+// a []FormField of the photofunia package.
+func fieldLiteral(field photofunia.FormField) string {
+	parts := []string{"Kind: Field" + goName(string(field.Kind)), fmt.Sprintf("Name: %q", field.Name)}
+	if len(field.Values) > 0 {
+		values := make([]string, len(field.Values))
+		for i, value := range field.Values {
+			values[i] = fmt.Sprintf("%q", value)
+		}
+		parts = append(parts, "Values: []string{"+strings.Join(values, ", ")+"}")
+	}
+	if field.Default != "" {
+		parts = append(parts, fmt.Sprintf("Default: %q", field.Default))
+	}
+	if field.Required {
+		parts = append(parts, "Required: true")
+	}
+	if field.MaxLength > 0 {
+		parts = append(parts, fmt.Sprintf("MaxLength: %d", field.MaxLength))
+	}
+	if field.Charset != "" {
+		parts = append(parts, fmt.Sprintf("Charset: %q", field.Charset))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// goName converts a form field name or value such as "fat_maker" or
+// "current-category" into an exported Go identifier such as "FatMaker".
+func goName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by photofunia-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"io"
+)
+{{range $effect := .Effects}}{{range $option := .Options}}{{if .Values}}
+// {{.Type}} is a value of the "{{.Name}}" field of the {{$effect.Path}} effect.
+type {{.Type}} string
+
+// Values of the "{{.Name}}" field of the {{$effect.Path}} effect.
+const (
+{{- range .Values}}
+	{{.Name}} {{$option.Type}} = {{printf "%q" .Value}}
+{{- end}}
+)
+{{end}}{{end}}
+// {{.Var}} is the form schema of the {{.Path}} effect.
+var {{.Var}} = &Schema{
+	Path: {{printf "%q" .Path}},
+	Fields: []FormField{
+{{- range .Fields}}
+		{{.}},
+{{- end}}
+	},
+}
+
+// {{.Name}}Options holds the options of the {{.Path}} effect.
+type {{.Name}}Options struct {
+{{- range .Options}}
+	// {{.Field}} sets the "{{.Name}}" field.{{if .Default}} Defaults to {{printf "%q" .Default}} when empty.{{end}}{{if .MaxLength}}
+	// It is limited to {{.MaxLength}} characters.{{end}}
+	{{.Field}} {{.Type}}
+{{- end}}
+}
+
+// {{.Name}}WithContext applies the {{.Path}} effect to the provided image with context support.
+// It returns the processed image data as a byte slice.
+//
+// The ctx parameter allows for cancellation and timeout control.
+// The img parameter should be an io.ReadCloser containing the image data.
+// The function will close the reader when done.
+//
+// The options are validated against the schema of the effect before the
+// image is uploaded; invalid options are reported as *ValidationError values.
+func (c *PhotoFuniaClient) {{.Name}}WithContext(ctx context.Context, img io.ReadCloser, opts {{.Name}}Options) ([]byte, error) {
+	params := make(map[string]string)
+{{range .Options}}{{if eq .Kind "checkbox"}}
+	if opts.{{.Field}} {
+		params[{{printf "%q" .Name}}] = {{printf "%q" .On}}
+	} else {
+		params[{{printf "%q" .Name}}] = {{printf "%q" .Off}}
+	}
+{{else}}
+	if opts.{{.Field}} != "" {
+		params[{{printf "%q" .Name}}] = {{if eq .Type "string"}}opts.{{.Field}}{{else}}string(opts.{{.Field}}){{end}}
+	}
+{{end}}{{end}}
+	return c.ApplyEffect(ctx, {{.Var}}.effect({{printf "%q" .Slug}}), img, params)
+}
+{{end}}`))
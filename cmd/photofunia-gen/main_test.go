@@ -0,0 +1,123 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swiftyspiffy/photofunia"
+)
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"fat_maker":        "FatMaker",
+		"current-category": "CurrentCategory",
+		"hat":              "Hat",
+		"XXXXXL":           "XXXXXL",
+		"text2":            "Text2",
+	}
+
+	for in, want := range tests {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	schemas := []*photofunia.Schema{
+		{
+			Path: "faces/fat_maker",
+			Fields: []photofunia.FormField{
+				{Kind: photofunia.FieldHidden, Name: "current-category", Default: "faces"},
+				{Kind: photofunia.FieldImage, Name: "image", Required: true},
+				{Kind: photofunia.FieldSelect, Name: "size", Values: []string{"S", "XXXXXL"}, Default: "XXXXXL"},
+			},
+		},
+		{
+			Path: "faces/clown",
+			Fields: []photofunia.FormField{
+				{Kind: photofunia.FieldImage, Name: "image", Required: true},
+				{Kind: photofunia.FieldCheckbox, Name: "hat", Values: []string{"1", "off"}},
+				{Kind: photofunia.FieldText, Name: "caption", MaxLength: 20},
+			},
+		},
+	}
+
+	src, err := generate("photofunia", schemas)
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "effects_gen.go", src, 0); err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+
+	for _, want := range []string{
+		"type FatMakerSize string",
+		`FatMakerSizeXXXXXL FatMakerSize = "XXXXXL"`,
+		"Size FatMakerSize",
+		"func (c *PhotoFuniaClient) FatMakerWithContext(ctx context.Context, img io.ReadCloser, opts FatMakerOptions) ([]byte, error)",
+		`{Kind: FieldHidden, Name: "current-category", Default: "faces"},`,
+		`{Kind: FieldSelect, Name: "size", Values: []string{"S", "XXXXXL"}, Default: "XXXXXL"},`,
+		`{Kind: FieldText, Name: "caption", MaxLength: 20},`,
+		"Hat bool",
+		"Caption string",
+		`params["hat"] = "1"`,
+		`return c.ApplyEffect(ctx, clownSchema.effect("clown"), img, params)`,
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(string(src)), " "), want) {
+			t.Errorf("generated code does not contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateRejectsUnsupportedSchemas(t *testing.T) {
+	tests := map[string]*photofunia.Schema{
+		"No image": {
+			Path:   "lab/writing_on_sand",
+			Fields: []photofunia.FormField{{Kind: photofunia.FieldText, Name: "text"}},
+		},
+		"Unknown kind": {
+			Path: "faces/clown",
+			Fields: []photofunia.FormField{
+				{Kind: photofunia.FieldImage, Name: "image"},
+				{Kind: "range", Name: "amount"},
+			},
+		},
+	}
+
+	for name, schema := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := generate("photofunia", []*photofunia.Schema{schema}); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestGeneratedFileUpToDate(t *testing.T) {
+	root := filepath.Join("..", "..")
+
+	schemas, err := loadSchemas(filepath.Join(root, "schemas"))
+	if err != nil {
+		t.Fatalf("loadSchemas() error = %v", err)
+	}
+
+	src, err := generate("photofunia", schemas)
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+
+	current, err := os.ReadFile(filepath.Join(root, "effects_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(current) != string(src) {
+		t.Error("effects_gen.go is out of date, run go generate")
+	}
+}
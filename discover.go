@@ -0,0 +1,284 @@
+package photofunia
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DiscoveredEffect describes an effect found by crawling the PhotoFunia category pages.
+type DiscoveredEffect struct {
+	// Path is the category path of the effect, for example "faces/fat_maker".
+	Path string
+
+	// Title is the display title of the effect.
+	Title string
+
+	// ThumbnailURL is the absolute URL of the effect's preview image.
+	ThumbnailURL string
+
+	// Fields lists the names of the form fields the effect page submits.
+	Fields []string
+
+	// Schema describes the typed form fields of the effect page.
+	// It is nil if the effect page has no effect form.
+	Schema *Schema
+}
+
+// Effect returns an Effect that can be passed to ApplyEffect.
+// When the schema is known, the effect uses its defaults and inputs
+// and validates parameters against it.
+func (d DiscoveredEffect) Effect() Effect {
+	if d.Schema == nil {
+		return Effect{Name: d.Title, Path: d.Path}
+	}
+
+	effect := d.Schema.effect(d.Title)
+	effect.Path = d.Path
+	return effect
+}
+
+var (
+	anchorPattern   = regexp.MustCompile(`(?is)<a\s([^>]*)>(.*?)</a>`)
+	imgPattern      = regexp.MustCompile(`(?is)<img\s[^>]*>`)
+	formPattern     = regexp.MustCompile(`(?is)<form\s([^>]*)>(.*?)</form>`)
+	fieldPattern    = regexp.MustCompile(`(?is)<(input|select|textarea)\s[^>]*>`)
+	tagPattern      = regexp.MustCompile(`(?s)<[^>]*>`)
+	namePattern     = regexp.MustCompile(`(?is)<[^>]*class="[^"]*\bname\b[^"]*"[^>]*>(.*?)</`)
+	spacePattern    = regexp.MustCompile(`\s+`)
+	attrPatternTmpl = `(?is)(?:^|\s)%s\s*=\s*(?:"([^"]*)"|'([^']*)')`
+
+	boolAttrPatternTmpl = `(?is)(?:^|\s)%s(?:\s|=|/|$)`
+
+	// attrPatterns caches the compiled attribute patterns, keyed by template
+	// and attribute name.
+	attrPatterns sync.Map
+)
+
+// Discover crawls the PhotoFunia category pages and returns every effect found,
+// including the form fields each effect page expects.
+//
+// Effects listed in several categories are returned once, under the first
+// category path they were found in.
+func (c *PhotoFuniaClient) Discover(ctx context.Context) ([]DiscoveredEffect, error) {
+	categoriesPage, err := c.getPageWithContext(ctx, c.site()+"/categories")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories page: %w", err)
+	}
+
+	categories := parseCategoryLinks(categoriesPage)
+	c.logger.Info("discovered categories", Field{"count", len(categories)})
+
+	var effects []DiscoveredEffect
+	seen := make(map[string]bool)
+
+	for _, category := range categories {
+		categoryPage, err := c.getPageWithContext(ctx, c.site()+"/categories/"+category)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get category page %s: %w", category, err)
+		}
+
+		for _, effect := range parseEffectLinks(categoryPage, c.site()) {
+			slug := effect.Path[strings.LastIndex(effect.Path, "/")+1:]
+			if seen[slug] {
+				continue
+			}
+			seen[slug] = true
+
+			effectPage, err := c.getPageWithContext(ctx, c.site()+"/categories/"+effect.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get effect page %s: %w", effect.Path, err)
+			}
+
+			schema, err := ParseSchema(effectPage)
+			if err != nil {
+				c.logger.Debug("effect page has no effect form", Field{"path", effect.Path})
+			} else {
+				effect.Schema = schema
+				effect.Fields = schema.Names()
+			}
+
+			effects = append(effects, effect)
+
+			c.logger.Debug("discovered effect", Field{"path", effect.Path}, Field{"fields", effect.Fields})
+		}
+	}
+
+	c.logger.Info("discovered effects", Field{"count", len(effects)})
+	return effects, nil
+}
+
+func (c *PhotoFuniaClient) getPageWithContext(ctx context.Context, pageURL string) ([]byte, error) {
+	req, err := c.createRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, asStageError(StageDiscovery, err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, stageError(StageDiscovery, nil, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(StageDiscovery, resp, "server returned non-OK status: %s")
+	}
+
+	page, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, stageError(StageDiscovery, nil, fmt.Errorf("failed to read page: %w", err))
+	}
+	return page, nil
+}
+
+// parseCategoryLinks returns the category names linked from the categories page.
+func parseCategoryLinks(htmlContent []byte) []string {
+	var categories []string
+	seen := make(map[string]bool)
+
+	for _, match := range anchorPattern.FindAllSubmatch(htmlContent, -1) {
+		segments := categoryPathSegments(htmlAttr(string(match[1]), "href"))
+		if len(segments) != 1 || seen[segments[0]] {
+			continue
+		}
+		seen[segments[0]] = true
+		categories = append(categories, segments[0])
+	}
+
+	return categories
+}
+
+// parseEffectLinks returns the effects linked from a category page.
+// Relative thumbnail links are resolved against baseURL.
+// The Fields of the returned effects are left empty.
+func parseEffectLinks(htmlContent []byte, baseURL string) []DiscoveredEffect {
+	var effects []DiscoveredEffect
+	seen := make(map[string]bool)
+
+	for _, match := range anchorPattern.FindAllSubmatch(htmlContent, -1) {
+		attrs, inner := string(match[1]), string(match[2])
+
+		segments := categoryPathSegments(htmlAttr(attrs, "href"))
+		if len(segments) != 2 {
+			continue
+		}
+
+		path := segments[0] + "/" + segments[1]
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		effect := DiscoveredEffect{Path: path}
+
+		img := imgPattern.FindString(inner)
+		if img != "" {
+			effect.ThumbnailURL = resolveURL(baseURL, htmlAttr(img, "src"))
+		}
+
+		switch {
+		case htmlAttr(attrs, "title") != "":
+			effect.Title = htmlAttr(attrs, "title")
+		case namePattern.MatchString(inner):
+			effect.Title = htmlText(namePattern.FindStringSubmatch(inner)[1])
+		case htmlAttr(img, "alt") != "":
+			effect.Title = htmlAttr(img, "alt")
+		default:
+			effect.Title = htmlText(inner)
+		}
+
+		effects = append(effects, effect)
+	}
+
+	return effects
+}
+
+// effectFormMatch returns the submatches of the first form on an effect page
+// that submits to a category path, or nil if there is none. Other forms, such
+// as the site search, are ignored.
+func effectFormMatch(htmlContent []byte) [][]byte {
+	for _, match := range formPattern.FindAllSubmatch(htmlContent, -1) {
+		if categoryPathSegments(htmlAttr(string(match[1]), "action")) != nil {
+			return match
+		}
+	}
+	return nil
+}
+
+// categoryPathSegments returns the path segments following "/categories/"
+// in a link, or nil when the link does not point to a category page.
+func categoryPathSegments(href string) []string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil
+	}
+
+	path, ok := strings.CutPrefix(u.Path, "/categories/")
+	if !ok {
+		return nil
+	}
+
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+// htmlAttr returns the unescaped value of the named attribute in a tag,
+// or an empty string when the attribute is missing.
+func htmlAttr(tag, name string) string {
+	match := attrPattern(attrPatternTmpl, name).FindStringSubmatch(tag)
+	if match == nil {
+		return ""
+	}
+
+	return html.UnescapeString(match[1] + match[2])
+}
+
+// htmlBoolAttr reports whether a tag has the named boolean attribute,
+// such as "checked" or "required".
+func htmlBoolAttr(tag, name string) bool {
+	return attrPattern(boolAttrPatternTmpl, name).MatchString(tag)
+}
+
+// attrPattern returns the compiled pattern of a template for the named
+// attribute, compiling it on first use.
+func attrPattern(tmpl, name string) *regexp.Regexp {
+	key := [2]string{tmpl, name}
+	if pattern, ok := attrPatterns.Load(key); ok {
+		return pattern.(*regexp.Regexp)
+	}
+
+	pattern, _ := attrPatterns.LoadOrStore(key, regexp.MustCompile(fmt.Sprintf(tmpl, regexp.QuoteMeta(name))))
+	return pattern.(*regexp.Regexp)
+}
+
+// htmlText strips tags from an HTML fragment and returns its collapsed text.
+func htmlText(fragment string) string {
+	text := tagPattern.ReplaceAllString(fragment, " ")
+	text = spacePattern.ReplaceAllString(html.UnescapeString(text), " ")
+	return strings.TrimSpace(text)
+}
+
+// resolveURL resolves a possibly relative link against baseURL.
+func resolveURL(baseURL, link string) string {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return link
+	}
+
+	ref, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	return base.ResolveReference(ref).String()
+}
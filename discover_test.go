@@ -0,0 +1,150 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newFixtureTransport returns a MockTransport serving the HTML fixtures in
+// testdata/discover for the category and effect pages.
+func newFixtureTransport(t *testing.T) *MockTransport {
+	t.Helper()
+
+	return &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			if strings.Contains(req.URL.String(), "cookie-warning") {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Set-Cookie": []string{"PHPSESSID=test-session-id"}},
+					Body:       http.NoBody,
+				}, nil
+			}
+
+			segments := strings.Split(strings.TrimPrefix(req.URL.Path, "/categories"), "/")
+
+			var fixture string
+			switch len(segments) {
+			case 1:
+				fixture = "categories.html"
+			case 2:
+				fixture = "category_" + segments[1] + ".html"
+			case 3:
+				fixture = "effect_" + segments[2] + ".html"
+			}
+
+			data, err := os.ReadFile(filepath.Join("testdata", "discover", fixture))
+			if err != nil {
+				return &http.Response{
+					StatusCode: http.StatusNotFound,
+					Status:     "404 Not Found",
+					Body:       http.NoBody,
+				}, nil
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(data)),
+			}, nil
+		},
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: newFixtureTransport(t)},
+	}
+
+	effects, err := client.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	for i := range effects {
+		if effects[i].Schema == nil {
+			t.Errorf("effect %s has no schema", effects[i].Path)
+		}
+		effects[i].Schema = nil
+	}
+
+	want := []DiscoveredEffect{
+		{
+			Path:         "faces/fat_maker",
+			Title:        "Fat Maker",
+			ThumbnailURL: "https://cdn.photofunia.com/effects/fat_maker/icons/medium.jpg",
+			Fields:       []string{"current-category", "image", "image:crop", "size"},
+		},
+		{
+			Path:         "faces/clown",
+			Title:        "Clown",
+			ThumbnailURL: "https://cdn.photofunia.com/effects/clown/icons/medium.jpg",
+			Fields:       []string{"current-category", "image", "image:crop", "hat"},
+		},
+		{
+			Path:         "lab/writing_on_sand",
+			Title:        "Writing on Sand & Sea",
+			ThumbnailURL: "https://photofunia.com/effects/writing_on_sand/icons/medium.jpg",
+			Fields:       []string{"current-category", "text", "text2"},
+		},
+	}
+
+	if !reflect.DeepEqual(effects, want) {
+		t.Errorf("Discover() =\n%+v\nwant\n%+v", effects, want)
+	}
+}
+
+func TestDiscoverError(t *testing.T) {
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: &MockTransport{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				if strings.Contains(req.URL.String(), "cookie-warning") {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Set-Cookie": []string{"PHPSESSID=test-session-id"}},
+						Body:       http.NoBody,
+					}, nil
+				}
+				return nil, errors.New("network error")
+			},
+		}},
+	}
+
+	_, err := client.Discover(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "network error") {
+		t.Errorf("Discover() error = %v, want network error", err)
+	}
+}
+
+func TestParseCategoryLinks(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "discover", "categories.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := parseCategoryLinks(data)
+	want := []string{"faces", "lab"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCategoryLinks() = %v, want %v", got, want)
+	}
+}
+
+func TestAttrPatternCached(t *testing.T) {
+	if attrPattern(attrPatternTmpl, "href") != attrPattern(attrPatternTmpl, "href") {
+		t.Error("attrPattern() compiled the same pattern twice")
+	}
+	if attrPattern(attrPatternTmpl, "href") == attrPattern(boolAttrPatternTmpl, "href") {
+		t.Error("attrPattern() shared a pattern between templates")
+	}
+	if got := htmlAttr(`<a class="x" href="/categories/faces">`, "href"); got != "/categories/faces" {
+		t.Errorf("htmlAttr() = %q, want %q", got, "/categories/faces")
+	}
+}
@@ -0,0 +1,416 @@
+package photofunia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// InputKind identifies the type of value an effect input expects.
+type InputKind string
+
+const (
+	// InputImage is an input that expects an uploaded photo.
+	// The uploaded image key is sent as the value of the form field.
+	InputImage InputKind = "image"
+
+	// InputText is an input that expects a line of text, such as a sign or headline.
+	// The text is sent as the value of the form field.
+	InputText InputKind = "text"
+)
+
+// Charset restricts the characters accepted by a text input.
+type Charset string
+
+const (
+	// CharsetAny accepts any printable Unicode character.
+	CharsetAny Charset = ""
+
+	// CharsetASCII accepts printable ASCII characters only.
+	CharsetASCII Charset = "ascii"
+
+	// CharsetLatin1 accepts printable characters of the Latin-1 range only.
+	CharsetLatin1 Charset = "latin1"
+)
+
+// Input describes a single input that must be supplied to apply an effect.
+type Input struct {
+	// Name is the form field the input is sent as, for example "image".
+	Name string
+
+	// Kind is the type of value the input expects.
+	Kind InputKind
+
+	// Optional reports whether a text input may be left empty.
+	// Image inputs are always required.
+	Optional bool
+
+	// MaxLength is the maximum number of characters of a text input, or 0 if unlimited.
+	MaxLength int
+
+	// Charset restricts the characters of a text input.
+	Charset Charset
+}
+
+// validateText checks a text value against the limits of the input.
+func (i Input) validateText(value string) error {
+	if value == "" {
+		if !i.Optional {
+			return &ValidationError{Field: i.Name, Reason: "value is required"}
+		}
+		return nil
+	}
+
+	if i.MaxLength > 0 && len([]rune(value)) > i.MaxLength {
+		return &ValidationError{Field: i.Name, Reason: fmt.Sprintf("length exceeds %d characters", i.MaxLength)}
+	}
+
+	return i.Charset.validate(i.Name, value)
+}
+
+// validate checks that every character of value belongs to the charset.
+// Control characters other than line breaks are rejected by every charset.
+func (cs Charset) validate(field, value string) error {
+	for _, r := range value {
+		switch {
+		case r == '\n' || r == '\r':
+			continue
+		case r == utf8.RuneError || unicode.IsControl(r):
+			return &ValidationError{Field: field, Reason: fmt.Sprintf("contains invalid character %q", r)}
+		case cs == CharsetASCII && r > unicode.MaxASCII,
+			cs == CharsetLatin1 && r > unicode.MaxLatin1:
+			return &ValidationError{Field: field, Reason: fmt.Sprintf("character %q is not in charset %s", r, cs)}
+		}
+	}
+	return nil
+}
+
+// EffectInputs holds the values supplied for the inputs of an effect.
+type EffectInputs struct {
+	// Images maps image input names to readers containing the image data.
+	// The readers are closed when the effect has been applied.
+	Images map[string]io.ReadCloser
+
+	// Uploaded maps image input names to images uploaded earlier with Upload.
+	// An input must be supplied either in Images or in Uploaded, not both.
+	Uploaded map[string]*UploadedImage
+
+	// Text maps text input names to their values.
+	Text map[string]string
+}
+
+// close closes every image reader of the inputs.
+func (in EffectInputs) close() {
+	for _, img := range in.Images {
+		if img != nil {
+			img.Close()
+		}
+	}
+}
+
+// Effect describes a PhotoFunia effect that can be applied with ApplyEffect.
+//
+// Effects are identified by their category path, which is the part of the
+// effect page URL following "/categories/", for example "faces/fat_maker".
+type Effect struct {
+	// Name is a short, human-readable name used in log messages.
+	Name string
+
+	// Path is the category path of the effect, for example "faces/fat_maker".
+	Path string
+
+	// Params holds the default form parameters sent with the effect.
+	// Values can be overridden per call through ApplyEffect.
+	Params map[string]string
+
+	// Inputs lists the inputs that must be supplied to apply the effect.
+	// An effect without inputs and without a Schema is treated as taking a
+	// single image named "image".
+	Inputs []Input
+
+	// Schema optionally describes the effect form. When set, ApplyEffect
+	// validates the parameters against it before uploading anything.
+	Schema *Schema
+}
+
+// Category returns the category segment of the effect path,
+// for example "faces" for "faces/fat_maker".
+func (e Effect) Category() string {
+	category, _, _ := strings.Cut(e.Path, "/")
+	return category
+}
+
+// imageInputs returns the image inputs of the effect, defaulting to a single
+// input named "image" when neither inputs nor a schema are declared. Effects
+// described by a schema take exactly the images of the schema, if any.
+func (e Effect) imageInputs() []Input {
+	if len(e.Inputs) == 0 && e.Schema == nil {
+		return []Input{{Name: "image", Kind: InputImage}}
+	}
+	return e.inputsOfKind(InputImage)
+}
+
+// textInputs returns the text inputs of the effect.
+func (e Effect) textInputs() []Input {
+	return e.inputsOfKind(InputText)
+}
+
+func (e Effect) inputsOfKind(kind InputKind) []Input {
+	var inputs []Input
+	for _, input := range e.Inputs {
+		if input.Kind == kind {
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs
+}
+
+// params returns a copy of the default parameters with the overrides applied.
+// The "current-category" parameter is derived from the path when not set.
+func (e Effect) params(overrides map[string]string) map[string]string {
+	params := make(map[string]string, len(e.Params)+len(overrides)+1)
+	params["current-category"] = e.Category()
+
+	for key, value := range e.Params {
+		params[key] = value
+	}
+
+	for key, value := range overrides {
+		params[key] = value
+	}
+
+	return params
+}
+
+// ApplyEffect applies the given effect to the provided image with context support.
+// It returns the processed image data as a byte slice.
+//
+// The ctx parameter allows for cancellation and timeout control.
+// The input parameter should be an io.ReadCloser containing the image data.
+// The function will close the reader when done. It may be nil for effects
+// that do not take an image.
+//
+// The overrides parameter replaces or adds form parameters on top of the
+// effect's defaults, including the values of text inputs. It may be nil.
+func (c *PhotoFuniaClient) ApplyEffect(ctx context.Context, effect Effect, input io.ReadCloser, overrides map[string]string) ([]byte, error) {
+	result, err := c.ApplyEffectResult(ctx, effect, input, overrides)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// ApplyEffectResult is like ApplyEffect, but returns the processed image
+// along with metadata about the run.
+func (c *PhotoFuniaClient) ApplyEffectResult(ctx context.Context, effect Effect, input io.ReadCloser, overrides map[string]string) (*Result, error) {
+	inputs, err := effect.singleImageInputs(input)
+	if err != nil {
+		return nil, err
+	}
+	return c.ApplyEffectInputsResult(ctx, effect, inputs, overrides)
+}
+
+// singleImageInputs returns the inputs for an effect taking at most one image.
+// The input is closed if the effect does not take exactly one image.
+func (e Effect) singleImageInputs(input io.ReadCloser) (EffectInputs, error) {
+	inputs := EffectInputs{}
+	if input != nil {
+		images := e.imageInputs()
+		if len(images) != 1 {
+			input.Close()
+			return EffectInputs{}, fmt.Errorf("effect %s does not take exactly one image input", e.Path)
+		}
+		inputs.Images = map[string]io.ReadCloser{images[0].Name: input}
+	}
+	return inputs, nil
+}
+
+// ApplyEffectInputs applies the given effect to the provided inputs with context support.
+// It returns the processed image data as a byte slice.
+//
+// It supports effects taking text, any number of images, or a mix of both.
+// Text values are validated against the length and charset limits of the
+// effect inputs before any image is uploaded. Images are uploaded concurrently,
+// and each resulting image key is sent as the form field of its input, such as
+// "image2". Effects without image inputs skip the upload entirely.
+//
+// The ctx parameter allows for cancellation and timeout control.
+// The image readers in inputs are closed when done.
+//
+// The overrides parameter replaces or adds form parameters on top of the
+// effect's defaults. It may be nil.
+func (c *PhotoFuniaClient) ApplyEffectInputs(ctx context.Context, effect Effect, inputs EffectInputs, overrides map[string]string) ([]byte, error) {
+	result, err := c.ApplyEffectInputsResult(ctx, effect, inputs, overrides)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// ApplyEffectInputsResult is like ApplyEffectInputs, but returns the processed
+// image along with metadata about the run.
+func (c *PhotoFuniaClient) ApplyEffectInputsResult(ctx context.Context, effect Effect, inputs EffectInputs, overrides map[string]string) (*Result, error) {
+	params, imageFields, name, err := effect.prepareRun(inputs, overrides)
+	if err != nil {
+		return nil, err
+	}
+	return c.runEffectWithContext(ctx, effect.Path, params, inputs.Images, imageFields, name)
+}
+
+// prepareRun checks the inputs against the effect and returns what is needed to
+// run it: the form parameters, the names of the image inputs and the name used
+// in log messages. The image readers are closed if the inputs are invalid.
+func (e Effect) prepareRun(inputs EffectInputs, overrides map[string]string) (map[string]string, []string, string, error) {
+	params, err := e.prepare(inputs, overrides)
+	if err != nil {
+		inputs.close()
+		return nil, nil, "", err
+	}
+
+	var imageFields []string
+	for _, input := range e.imageInputs() {
+		imageFields = append(imageFields, input.Name)
+	}
+
+	name := e.Name
+	if name == "" {
+		name = e.Path
+	}
+
+	return params, imageFields, name, nil
+}
+
+// uploadImagesWithContext uploads the given images concurrently and returns
+// the resulting image keys by input name. Every reader is closed when done.
+// If any upload fails, the remaining uploads are cancelled, and the keys of
+// the images uploaded successfully are returned along with the error.
+func (c *PhotoFuniaClient) uploadImagesWithContext(ctx context.Context, images map[string]io.ReadCloser) (map[string]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type upload struct {
+		field string
+		key   string
+		err   error
+	}
+
+	results := make(chan upload, len(images))
+	for field, img := range images {
+		go func(field string, img io.ReadCloser) {
+			response, err := c.uploadImageWithRetry(ctx, img)
+			if err != nil {
+				results <- upload{field: field, err: fmt.Errorf("failed to upload image %s: %w", field, err)}
+				return
+			}
+
+			key := response.Key
+			if key == "" {
+				results <- upload{field: field, err: &Error{Stage: StageUpload, StatusCode: http.StatusOK, Err: fmt.Errorf("%w for image %s", ErrEmptyImageKey, field)}}
+				return
+			}
+
+			results <- upload{field: field, key: key}
+		}(field, img)
+	}
+
+	keys := make(map[string]string, len(images))
+	var firstErr error
+	for range images {
+		result := <-results
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+				cancel()
+			}
+			continue
+		}
+
+		c.logger.Info("got image key", Field{"field", result.field}, Field{"key", result.key})
+		keys[result.field] = result.key
+	}
+
+	return keys, firstErr
+}
+
+// prepare checks the inputs against the effect and returns the form parameters
+// to submit, without the image keys.
+func (e Effect) prepare(inputs EffectInputs, overrides map[string]string) (map[string]string, error) {
+	if e.Path == "" {
+		return nil, errors.New("effect path is empty")
+	}
+
+	images := e.imageInputs()
+	for _, input := range images {
+		img, uploaded := inputs.Images[input.Name], inputs.Uploaded[input.Name]
+		switch {
+		case img == nil && uploaded == nil:
+			return nil, fmt.Errorf("effect %s requires image input %s", e.Path, input.Name)
+		case img != nil && uploaded != nil:
+			return nil, fmt.Errorf("image input %s of effect %s is supplied twice", input.Name, e.Path)
+		case uploaded != nil && uploaded.Key == "":
+			return nil, fmt.Errorf("uploaded image for input %s has no key", input.Name)
+		case uploaded != nil && uploaded.Expired():
+			return nil, fmt.Errorf("uploaded image for input %s expired at %s", input.Name, uploaded.Expiry)
+		}
+	}
+	for name := range inputs.Images {
+		if !hasInput(images, name) {
+			return nil, fmt.Errorf("effect %s has no image input %s", e.Path, name)
+		}
+	}
+	for name := range inputs.Uploaded {
+		if !hasInput(images, name) {
+			return nil, fmt.Errorf("effect %s has no image input %s", e.Path, name)
+		}
+	}
+
+	texts := e.textInputs()
+	for name := range inputs.Text {
+		if !hasInput(texts, name) {
+			return nil, fmt.Errorf("effect %s has no text input %s", e.Path, name)
+		}
+	}
+
+	params := e.params(overrides)
+	for name, value := range inputs.Text {
+		params[name] = value
+	}
+	for name, uploaded := range inputs.Uploaded {
+		params[name] = uploaded.Key
+	}
+
+	var errs []error
+	for _, input := range texts {
+		// Text fields of the schema are validated along with the other fields.
+		if e.Schema != nil {
+			if _, ok := e.Schema.Lookup(input.Name); ok {
+				continue
+			}
+		}
+		if err := input.validateText(params[input.Name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if e.Schema != nil {
+		errs = append(errs, e.Schema.Validate(params))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid parameters for effect %s: %w", e.Path, err)
+	}
+
+	return params, nil
+}
+
+func hasInput(inputs []Input, name string) bool {
+	for _, input := range inputs {
+		if input.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,503 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newEffectTransport returns a MockTransport that serves a successful run of
+// the effect at effectPath, passing the submitted effect form to onApply.
+func newEffectTransport(t *testing.T, effectPath string, onApply func(form map[string]string)) *MockTransport {
+	t.Helper()
+
+	return &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			if strings.Contains(req.URL.String(), "cookie-warning") {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Set-Cookie": []string{"PHPSESSID=test-session-id"}},
+					Body:       http.NoBody,
+				}, nil
+			}
+
+			if strings.Contains(req.URL.String(), "/images") {
+				jsonResponse := `{"response":{"key":"test-image-key","server":1,"existed":false,"expiry":0,"created":0,"lifetime":0,"image":{"highres":{"url":"","width":0,"height":0},"preview":{"url":"","width":0,"height":0},"thumb":{"url":"","width":0,"height":0}},"sid":"test-sid"}}`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader(jsonResponse)),
+				}, nil
+			}
+
+			if strings.Contains(req.URL.String(), "/categories/"+effectPath) && req.Method == "POST" {
+				form, err := readMultipartForm(req)
+				if err != nil {
+					t.Errorf("failed to read effect form: %v", err)
+				}
+				if onApply != nil {
+					onApply(form)
+				}
+
+				resultURL, _ := url.Parse("https://photofunia.com/results/result123")
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Request:    &http.Request{URL: resultURL},
+					Body:       http.NoBody,
+				}, nil
+			}
+
+			if req.Method == "GET" && strings.Contains(req.URL.String(), "/results/") {
+				htmlContent := `<html><body><img id="result-image" src="https://example.com/result.jpg" alt="Result"></body></html>`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader(htmlContent)),
+				}, nil
+			}
+
+			if strings.Contains(req.URL.String(), "example.com/result.jpg") {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewReader([]byte("fake-image-data"))),
+				}, nil
+			}
+
+			return nil, errors.New("unexpected request")
+		},
+	}
+}
+
+// readMultipartForm reads the non-file fields of a multipart request body.
+func readMultipartForm(req *http.Request) (map[string]string, error) {
+	_, mediaParams, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	form := make(map[string]string)
+	reader := multipart.NewReader(req.Body, mediaParams["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		if part.FileName() == "" {
+			form[part.FormName()] = string(value)
+		}
+	}
+}
+
+func TestEffectCategory(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "faces/fat_maker", want: "faces"},
+		{path: "all_effects/clown", want: "all_effects"},
+		{path: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := (Effect{Path: tt.path}).Category(); got != tt.want {
+			t.Errorf("Category() for %q = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestApplyEffect(t *testing.T) {
+	var submitted map[string]string
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{
+			Transport: newEffectTransport(t, "faces/fat_maker", func(form map[string]string) {
+				submitted = form
+			}),
+		},
+	}
+
+	effect := Effect{
+		Name:   "fat maker",
+		Path:   "faces/fat_maker",
+		Params: map[string]string{"size": "XXXXXL", "image:crop": "0.0.961.1093"},
+	}
+
+	imageReader := io.NopCloser(bytes.NewReader([]byte("fake-image-data")))
+	result, err := client.ApplyEffect(context.Background(), effect, imageReader, map[string]string{"size": "L"})
+	if err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+
+	if string(result) != "fake-image-data" {
+		t.Errorf("ApplyEffect() = %v, want %v", string(result), "fake-image-data")
+	}
+
+	want := map[string]string{
+		"current-category": "faces",
+		"image:crop":       "0.0.961.1093",
+		"size":             "L",
+		"image":            "test-image-key",
+	}
+	for key, value := range want {
+		if submitted[key] != value {
+			t.Errorf("form field %s = %q, want %q", key, submitted[key], value)
+		}
+	}
+
+	if effect.Params["size"] != "XXXXXL" {
+		t.Errorf("ApplyEffect() modified the effect defaults: size = %q", effect.Params["size"])
+	}
+}
+
+func TestApplyEffectInvalid(t *testing.T) {
+	client := NewPhotoFuniaClient()
+
+	tests := []struct {
+		name   string
+		effect Effect
+		input  io.ReadCloser
+	}{
+		{
+			name:   "Empty path",
+			effect: Effect{},
+			input:  io.NopCloser(strings.NewReader("img")),
+		},
+		{
+			name:   "Missing image",
+			effect: Effect{Path: "faces/fat_maker"},
+			input:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.ApplyEffect(context.Background(), tt.effect, tt.input, nil); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestApplyEffectSchemaWithoutInputs(t *testing.T) {
+	schema := &Schema{
+		Path: "lab/random_pattern",
+		Fields: []FormField{
+			{Name: "current-category", Kind: FieldHidden},
+			{Name: "style", Kind: FieldSelect, Values: []string{"dots", "stripes"}},
+		},
+	}
+	effect := Effect{Name: "random pattern", Path: schema.Path, Params: map[string]string{"style": "dots"}, Schema: schema}
+	if effect.Inputs = schema.Inputs(); effect.Inputs != nil {
+		t.Fatalf("Inputs() = %+v, want none", effect.Inputs)
+	}
+
+	var form map[string]string
+	transport := newEffectTransport(t, "lab/random_pattern", func(f map[string]string) { form = f })
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "/images") {
+			t.Error("unexpected upload for an effect without image inputs")
+		}
+		return roundTrip(req)
+	}
+	client := &PhotoFuniaClient{logger: &MockLogger{}, client: &http.Client{Transport: transport}}
+
+	if _, err := client.ApplyEffect(context.Background(), effect, nil, nil); err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+	if form["style"] != "dots" {
+		t.Errorf("form style = %q, want %q", form["style"], "dots")
+	}
+}
+
+func TestApplyEffectSchemaTextValidatedOnce(t *testing.T) {
+	entry, ok := DefaultCatalog().ByPath("lab/writing_on_sand")
+	if !ok {
+		t.Fatal("lab/writing_on_sand is not in the default catalog")
+	}
+	client := &PhotoFuniaClient{logger: &MockLogger{}, client: &http.Client{Transport: &MockTransport{}}}
+
+	inputs := EffectInputs{Text: map[string]string{"text": "a message far too long for the sand"}}
+	_, err := client.ApplyEffectInputs(context.Background(), entry.Effect(), inputs, nil)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "text" {
+		t.Fatalf("ApplyEffectInputs() error = %v, want *ValidationError for text", err)
+	}
+	if got := strings.Count(err.Error(), "invalid value for field text"); got != 1 {
+		t.Errorf("ApplyEffectInputs() error = %v, want the text field reported once, got %d times", err, got)
+	}
+}
+
+func TestApplyEffectInputsText(t *testing.T) {
+	sand := Effect{
+		Name:   "writing on sand",
+		Path:   "lab/writing_on_sand",
+		Inputs: []Input{{Name: "text", Kind: InputText, MaxLength: 15, Charset: CharsetASCII}},
+	}
+	poster := Effect{
+		Name: "poster",
+		Path: "posters/poster",
+		Inputs: []Input{
+			{Name: "image", Kind: InputImage},
+			{Name: "title", Kind: InputText, MaxLength: 20},
+			{Name: "subtitle", Kind: InputText, Optional: true},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		effect     Effect
+		image      bool
+		text       map[string]string
+		want       map[string]string
+		wantUpload bool
+	}{
+		{
+			name:   "Text only",
+			effect: sand,
+			text:   map[string]string{"text": "hello world"},
+			want:   map[string]string{"current-category": "lab", "text": "hello world"},
+		},
+		{
+			name:       "Text and image",
+			effect:     poster,
+			image:      true,
+			text:       map[string]string{"title": "Wanted"},
+			want:       map[string]string{"current-category": "posters", "title": "Wanted", "image": "test-image-key"},
+			wantUpload: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var submitted map[string]string
+			transport := newEffectTransport(t, tt.effect.Path, func(form map[string]string) {
+				submitted = form
+			})
+
+			uploaded := false
+			roundTrip := transport.RoundTripFunc
+			transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+				if strings.Contains(req.URL.String(), "/images") {
+					uploaded = true
+				}
+				return roundTrip(req)
+			}
+
+			client := &PhotoFuniaClient{
+				logger: &MockLogger{},
+				client: &http.Client{Transport: transport},
+			}
+
+			inputs := EffectInputs{Text: tt.text}
+			if tt.image {
+				inputs.Images = map[string]io.ReadCloser{"image": io.NopCloser(strings.NewReader("fake-image-data"))}
+			}
+
+			result, err := client.ApplyEffectInputs(context.Background(), tt.effect, inputs, nil)
+			if err != nil {
+				t.Fatalf("ApplyEffectInputs() error = %v", err)
+			}
+
+			if string(result) != "fake-image-data" {
+				t.Errorf("ApplyEffectInputs() = %v, want %v", string(result), "fake-image-data")
+			}
+			if uploaded != tt.wantUpload {
+				t.Errorf("uploaded = %v, want %v", uploaded, tt.wantUpload)
+			}
+			for key, value := range tt.want {
+				if submitted[key] != value {
+					t.Errorf("form field %s = %q, want %q", key, submitted[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyEffectInputsInvalidText(t *testing.T) {
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: &MockTransport{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				t.Errorf("unexpected request to %s", req.URL)
+				return nil, errors.New("unexpected request")
+			},
+		}},
+	}
+
+	effect := Effect{
+		Path: "lab/writing_on_sand",
+		Inputs: []Input{
+			{Name: "image", Kind: InputImage},
+			{Name: "text", Kind: InputText, MaxLength: 5, Charset: CharsetASCII},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		text      map[string]string
+		wantField string
+	}{
+		{name: "Missing text", text: nil, wantField: "text"},
+		{name: "Too long", text: map[string]string{"text": "too long"}, wantField: "text"},
+		{name: "Outside charset", text: map[string]string{"text": "héllo"}, wantField: "text"},
+		{name: "Control character", text: map[string]string{"text": "a\x00b"}, wantField: "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closed := false
+			img := &closeTracker{Reader: strings.NewReader("fake-image-data"), closed: &closed}
+
+			inputs := EffectInputs{
+				Images: map[string]io.ReadCloser{"image": img},
+				Text:   tt.text,
+			}
+
+			_, err := client.ApplyEffectInputs(context.Background(), effect, inputs, nil)
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+				t.Errorf("ApplyEffectInputs() error = %v, want validation error for %s", err, tt.wantField)
+			}
+			if !closed {
+				t.Error("image reader was not closed")
+			}
+		})
+	}
+
+	_, err := client.ApplyEffectInputs(context.Background(), effect, EffectInputs{
+		Images: map[string]io.ReadCloser{"image": io.NopCloser(strings.NewReader("img"))},
+		Text:   map[string]string{"caption": "hi"},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no text input caption") {
+		t.Errorf("ApplyEffectInputs() error = %v, want unknown input error", err)
+	}
+}
+
+type closeTracker struct {
+	io.Reader
+	closed *bool
+}
+
+func (c *closeTracker) Close() error {
+	*c.closed = true
+	return nil
+}
+
+func TestApplyEffectInputsMultipleImages(t *testing.T) {
+	var submitted map[string]string
+	transport := newEffectTransport(t, "frames/couple", func(form map[string]string) {
+		submitted = form
+	})
+
+	var mu sync.Mutex
+	uploads := 0
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if !strings.Contains(req.URL.String(), "/images") {
+			return roundTrip(req)
+		}
+
+		mu.Lock()
+		uploads++
+		mu.Unlock()
+
+		// Derive the image key from the uploaded data so that each input
+		// can be matched to its form field.
+		body, _ := io.ReadAll(req.Body)
+		key := "key-other"
+		if bytes.Contains(body, []byte("second-image")) {
+			key = "key-second"
+		} else if bytes.Contains(body, []byte("first-image")) {
+			key = "key-first"
+		}
+
+		jsonResponse := `{"response":{"key":"` + key + `","server":1}}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(jsonResponse)),
+		}, nil
+	}
+
+	client := &PhotoFuniaClient{
+		logger: NoopLogger{},
+		client: &http.Client{Transport: transport},
+	}
+
+	effect := Effect{
+		Path: "frames/couple",
+		Inputs: []Input{
+			{Name: "image", Kind: InputImage},
+			{Name: "image2", Kind: InputImage},
+		},
+	}
+
+	inputs := EffectInputs{Images: map[string]io.ReadCloser{
+		"image":  io.NopCloser(strings.NewReader("first-image")),
+		"image2": io.NopCloser(strings.NewReader("second-image")),
+	}}
+
+	if _, err := client.ApplyEffectInputs(context.Background(), effect, inputs, nil); err != nil {
+		t.Fatalf("ApplyEffectInputs() error = %v", err)
+	}
+
+	if uploads != 2 {
+		t.Errorf("uploads = %d, want 2", uploads)
+	}
+	if submitted["image"] != "key-first" || submitted["image2"] != "key-second" {
+		t.Errorf("image fields = %q, %q, want key-first, key-second", submitted["image"], submitted["image2"])
+	}
+}
+
+func TestApplyEffectInputsUploadFailure(t *testing.T) {
+	transport := newEffectTransport(t, "frames/couple", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "/images") {
+			body, _ := io.ReadAll(req.Body)
+			if bytes.Contains(body, []byte("second-image")) {
+				return nil, errors.New("network error")
+			}
+		}
+		return roundTrip(req)
+	}
+
+	client := &PhotoFuniaClient{
+		logger: NoopLogger{},
+		client: &http.Client{Transport: transport},
+	}
+
+	effect := Effect{
+		Path: "frames/couple",
+		Inputs: []Input{
+			{Name: "image", Kind: InputImage},
+			{Name: "image2", Kind: InputImage},
+		},
+	}
+
+	inputs := EffectInputs{Images: map[string]io.ReadCloser{
+		"image":  io.NopCloser(strings.NewReader("first-image")),
+		"image2": io.NopCloser(strings.NewReader("second-image")),
+	}}
+
+	_, err := client.ApplyEffectInputs(context.Background(), effect, inputs, nil)
+	if err == nil || !strings.Contains(err.Error(), "image2") || !strings.Contains(err.Error(), "network error") {
+		t.Errorf("ApplyEffectInputs() error = %v, want upload error for image2", err)
+	}
+}
@@ -0,0 +1,73 @@
+package photofunia
+
+//go:generate go run ./cmd/photofunia-gen -schemas schemas -out effects_gen.go
+
+// The effects below belong to PhotoFunia's "faces" category. Each of them
+// takes a single photo containing a face, sent as the "image" form field.
+// None of them sets the "image:crop" field, which the effect form leaves empty;
+// pass it in the overrides of ApplyEffect to crop the photo.
+
+// FatMaker makes faces appear fatter.
+// The "size" parameter controls the amount of fat, from "S" up to "XXXXXL".
+var FatMaker = Effect{
+	Name: "fatify",
+	Path: "faces/fat_maker",
+	Params: map[string]string{
+		"size": "XXXXXL",
+	},
+	Inputs: []Input{{Name: "image", Kind: InputImage}},
+}
+
+// Clown adds clown makeup to faces.
+// The "hat" parameter adds a clown hat when set to "on".
+var Clown = Effect{
+	Name: "clownify",
+	Path: "faces/clown",
+	Params: map[string]string{
+		"hat": "off",
+	},
+	Inputs: []Input{{Name: "image", Kind: InputImage}},
+}
+
+// Emotions changes the facial expression of a face.
+var Emotions = Effect{
+	Name:   "emotions",
+	Path:   "faces/emotions",
+	Inputs: []Input{{Name: "image", Kind: InputImage}},
+}
+
+// Zombie turns a face into a zombie.
+var Zombie = Effect{
+	Name:   "zombie",
+	Path:   "faces/zombie",
+	Inputs: []Input{{Name: "image", Kind: InputImage}},
+}
+
+// Alien turns a face into an alien.
+var Alien = Effect{
+	Name:   "alien",
+	Path:   "faces/alien",
+	Inputs: []Input{{Name: "image", Kind: InputImage}},
+}
+
+// Vampire turns a face into a vampire.
+var Vampire = Effect{
+	Name:   "vampire",
+	Path:   "faces/vampire",
+	Inputs: []Input{{Name: "image", Kind: InputImage}},
+}
+
+// FacesEffects returns the effects of PhotoFunia's "faces" category known to
+// this package. PhotoFunia may offer more; use Discover to list them all.
+// The slice is newly allocated on each call, but the Params maps are shared
+// with the package-level effect variables and must not be modified.
+func FacesEffects() []Effect {
+	return []Effect{
+		FatMaker,
+		Clown,
+		Emotions,
+		Zombie,
+		Alien,
+		Vampire,
+	}
+}
@@ -0,0 +1,61 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestFacesEffects(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, effect := range FacesEffects() {
+		if effect.Category() != "faces" {
+			t.Errorf("effect %s is not in the faces category", effect.Path)
+		}
+		if effect.Name == "" {
+			t.Errorf("effect %s has no name", effect.Path)
+		}
+		if len(effect.imageInputs()) != 1 {
+			t.Errorf("effect %s does not take exactly one image", effect.Path)
+		}
+		if seen[effect.Path] {
+			t.Errorf("effect %s is listed twice", effect.Path)
+		}
+		seen[effect.Path] = true
+	}
+}
+
+func TestFacesEffectsApply(t *testing.T) {
+	for _, effect := range FacesEffects() {
+		t.Run(effect.Name, func(t *testing.T) {
+			var submitted map[string]string
+			client := &PhotoFuniaClient{
+				logger: &MockLogger{},
+				client: &http.Client{
+					Transport: newEffectTransport(t, effect.Path, func(form map[string]string) {
+						submitted = form
+					}),
+				},
+			}
+
+			imageReader := io.NopCloser(bytes.NewReader([]byte("fake-image-data")))
+			if _, err := client.ApplyEffect(context.Background(), effect, imageReader, nil); err != nil {
+				t.Fatalf("ApplyEffect() error = %v", err)
+			}
+
+			if submitted["current-category"] != "faces" {
+				t.Errorf("current-category = %q, want %q", submitted["current-category"], "faces")
+			}
+			if submitted["image:crop"] != "" {
+				t.Errorf("image:crop = %q, want it empty", submitted["image:crop"])
+			}
+			for key, value := range effect.Params {
+				if submitted[key] != value {
+					t.Errorf("form field %s = %q, want %q", key, submitted[key], value)
+				}
+			}
+		})
+	}
+}
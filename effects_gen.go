@@ -0,0 +1,97 @@
+// Code generated by photofunia-gen. DO NOT EDIT.
+
+package photofunia
+
+import (
+	"context"
+	"io"
+)
+
+// clownSchema is the form schema of the faces/clown effect.
+var clownSchema = &Schema{
+	Path: "faces/clown",
+	Fields: []FormField{
+		{Kind: FieldHidden, Name: "current-category", Default: "faces"},
+		{Kind: FieldImage, Name: "image", Required: true},
+		{Kind: FieldHidden, Name: "image:crop"},
+		{Kind: FieldCheckbox, Name: "hat", Values: []string{"on", "off"}, Default: "off"},
+	},
+}
+
+// ClownOptions holds the options of the faces/clown effect.
+type ClownOptions struct {
+	// Hat sets the "hat" field.
+	Hat bool
+}
+
+// ClownWithContext applies the faces/clown effect to the provided image with context support.
+// It returns the processed image data as a byte slice.
+//
+// The ctx parameter allows for cancellation and timeout control.
+// The img parameter should be an io.ReadCloser containing the image data.
+// The function will close the reader when done.
+//
+// The options are validated against the schema of the effect before the
+// image is uploaded; invalid options are reported as *ValidationError values.
+func (c *PhotoFuniaClient) ClownWithContext(ctx context.Context, img io.ReadCloser, opts ClownOptions) ([]byte, error) {
+	params := make(map[string]string)
+
+	if opts.Hat {
+		params["hat"] = "on"
+	} else {
+		params["hat"] = "off"
+	}
+
+	return c.ApplyEffect(ctx, clownSchema.effect("clown"), img, params)
+}
+
+// FatMakerSize is a value of the "size" field of the faces/fat_maker effect.
+type FatMakerSize string
+
+// Values of the "size" field of the faces/fat_maker effect.
+const (
+	FatMakerSizeS      FatMakerSize = "S"
+	FatMakerSizeM      FatMakerSize = "M"
+	FatMakerSizeL      FatMakerSize = "L"
+	FatMakerSizeXL     FatMakerSize = "XL"
+	FatMakerSizeXXL    FatMakerSize = "XXL"
+	FatMakerSizeXXXL   FatMakerSize = "XXXL"
+	FatMakerSizeXXXXL  FatMakerSize = "XXXXL"
+	FatMakerSizeXXXXXL FatMakerSize = "XXXXXL"
+)
+
+// fatMakerSchema is the form schema of the faces/fat_maker effect.
+var fatMakerSchema = &Schema{
+	Path: "faces/fat_maker",
+	Fields: []FormField{
+		{Kind: FieldHidden, Name: "current-category", Default: "faces"},
+		{Kind: FieldImage, Name: "image", Required: true},
+		{Kind: FieldHidden, Name: "image:crop"},
+		{Kind: FieldSelect, Name: "size", Values: []string{"S", "M", "L", "XL", "XXL", "XXXL", "XXXXL", "XXXXXL"}, Default: "XXXXXL"},
+	},
+}
+
+// FatMakerOptions holds the options of the faces/fat_maker effect.
+type FatMakerOptions struct {
+	// Size sets the "size" field. Defaults to "XXXXXL" when empty.
+	Size FatMakerSize
+}
+
+// FatMakerWithContext applies the faces/fat_maker effect to the provided image with context support.
+// It returns the processed image data as a byte slice.
+//
+// The ctx parameter allows for cancellation and timeout control.
+// The img parameter should be an io.ReadCloser containing the image data.
+// The function will close the reader when done.
+//
+// The options are validated against the schema of the effect before the
+// image is uploaded; invalid options are reported as *ValidationError values.
+func (c *PhotoFuniaClient) FatMakerWithContext(ctx context.Context, img io.ReadCloser, opts FatMakerOptions) ([]byte, error) {
+	params := make(map[string]string)
+
+	if opts.Size != "" {
+		params["size"] = string(opts.Size)
+	}
+
+	return c.ApplyEffect(ctx, fatMakerSchema.effect("fat_maker"), img, params)
+}
@@ -0,0 +1,59 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGeneratedSchemasMatchCatalog(t *testing.T) {
+	for _, schema := range []*Schema{clownSchema, fatMakerSchema} {
+		entry, ok := DefaultCatalog().ByPath(schema.Path)
+		if !ok {
+			t.Errorf("effect %s is missing from the catalog", schema.Path)
+			continue
+		}
+		if !reflect.DeepEqual(entry.Schema, *schema) {
+			t.Errorf("schema of %s = %+v, catalog has %+v", schema.Path, *schema, entry.Schema)
+		}
+	}
+}
+
+func TestGeneratedWrapperForm(t *testing.T) {
+	var submitted map[string]string
+	client := NewClient(WithTransport(newEffectTransport(t, "faces/fat_maker", func(form map[string]string) {
+		submitted = form
+	})))
+
+	imageReader := io.NopCloser(bytes.NewReader([]byte("fake-image-data")))
+	if _, err := client.FatMakerWithContext(context.Background(), imageReader, FatMakerOptions{}); err != nil {
+		t.Fatalf("FatMakerWithContext() error = %v", err)
+	}
+
+	want := map[string]string{"current-category": "faces", "size": "XXXXXL", "image": "test-image-key"}
+	for key, value := range want {
+		if submitted[key] != value {
+			t.Errorf("form field %s = %q, want %q", key, submitted[key], value)
+		}
+	}
+}
+
+func TestGeneratedWrapperValidates(t *testing.T) {
+	transport := &MockTransport{RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request to %s", req.URL)
+		return nil, errors.New("unexpected request")
+	}}
+	client := NewClient(WithTransport(transport))
+
+	imageReader := io.NopCloser(bytes.NewReader([]byte("fake-image-data")))
+	_, err := client.FatMakerWithContext(context.Background(), imageReader, FatMakerOptions{Size: "bogus"})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "size" {
+		t.Errorf("FatMakerWithContext() error = %v, want a ValidationError for size", err)
+	}
+}
@@ -0,0 +1,154 @@
+package photofunia
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Stage identifies a step of applying an effect.
+type Stage string
+
+const (
+	// StageSession is obtaining a PHPSESSID.
+	StageSession Stage = "session"
+
+	// StageUpload is uploading an input image.
+	StageUpload Stage = "upload"
+
+	// StageApply is posting the effect form.
+	StageApply Stage = "apply"
+
+	// StageResultPage is reading the result page.
+	StageResultPage Stage = "result page"
+
+	// StageDownload is downloading the result image.
+	StageDownload Stage = "download"
+
+	// StageDiscovery is fetching a category or effect page, as done by
+	// Discover and EffectSchema.
+	StageDiscovery Stage = "discovery"
+)
+
+var (
+	// ErrSessionUnavailable is matched by every error of the session stage.
+	ErrSessionUnavailable = errors.New("PHPSESSID is unavailable")
+
+	// ErrSessionExpired reports that PhotoFunia no longer accepts the PHPSESSID,
+	// for example by redirecting a request back to the cookie warning. The
+	// client replaces the session and repeats the failed stage once before
+	// returning it. A result page missing right after the effect was applied
+	// also makes the client replace the session and apply the effect again,
+	// but a page still missing after that is reported with its 404 status.
+	ErrSessionExpired = errors.New("PHPSESSID has expired")
+
+	// ErrNoResultImage reports a result page without a result image.
+	ErrNoResultImage = errors.New("could not find result image in HTML")
+
+	// ErrEmptyImageKey reports an upload response without an image key.
+	ErrEmptyImageKey = errors.New("image key is empty in the response")
+)
+
+// maxErrorBodySize is the number of bytes of a response body kept in an Error.
+const maxErrorBodySize = 1024
+
+// Error describes the failure of a stage of applying an effect.
+// Use errors.As to get it from the errors returned by the client.
+type Error struct {
+	// Stage is the stage that failed.
+	Stage Stage
+
+	// StatusCode is the HTTP status of the response, or 0 if no response was received.
+	StatusCode int
+
+	// Effect is the name of the effect being applied, if any.
+	Effect string
+
+	// Body holds the start of the response body, truncated to 1 KiB.
+	Body string
+
+	// RetryAfter is the delay requested by the Retry-After header of the
+	// response, or 0 if there was none.
+	RetryAfter time.Duration
+
+	// Checkpoint holds the progress of the run up to the failed stage, from
+	// which it can be continued with Resume. It is nil for errors raised
+	// outside of applying an effect, such as those of StageDiscovery.
+	Checkpoint *Checkpoint
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Effect != "" {
+		return fmt.Sprintf("photofunia %s %s: %v", e.Effect, e.Stage, e.Err)
+	}
+	return fmt.Sprintf("photofunia %s: %v", e.Stage, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrSessionUnavailable for errors of the session stage.
+func (e *Error) Is(target error) bool {
+	return target == ErrSessionUnavailable && e.Stage == StageSession
+}
+
+// stageError returns an Error for the stage. When resp is not nil, its
+// status and the start of its body are kept.
+func stageError(stage Stage, resp *http.Response, err error) *Error {
+	stageErr := &Error{Stage: stage, Err: err}
+	if resp != nil {
+		stageErr.StatusCode = resp.StatusCode
+		stageErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		stageErr.Body = truncateBody(body)
+	}
+	return stageErr
+}
+
+// statusError returns an Error for a response with an unexpected status.
+func statusError(stage Stage, resp *http.Response, format string) *Error {
+	return stageError(stage, resp, fmt.Errorf(format, resp.Status))
+}
+
+// asStageError returns err unchanged if it already is an Error, such as a
+// session error raised while creating a request, and an Error for the stage otherwise.
+func asStageError(stage Stage, err error) error {
+	var stageErr *Error
+	if errors.As(err, &stageErr) {
+		return err
+	}
+	return stageError(stage, nil, err)
+}
+
+// truncateBody returns the start of a response body, as kept in an Error.
+func truncateBody(body []byte) string {
+	if len(body) > maxErrorBodySize {
+		body = body[:maxErrorBodySize]
+	}
+	return strings.ToValidUTF8(string(body), "")
+}
+
+// withEffect sets the effect name of the Error in err, if any.
+func withEffect(err error, effectName string) error {
+	var stageErr *Error
+	if errors.As(err, &stageErr) && stageErr.Effect == "" {
+		stageErr.Effect = effectName
+	}
+	return err
+}
+
+// hasStatus reports whether err holds an Error for a response with the given
+// HTTP status.
+func hasStatus(err error, status int) bool {
+	var stageErr *Error
+	return errors.As(err, &stageErr) && stageErr.StatusCode == status
+}
@@ -0,0 +1,173 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStageErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		match     string
+		method    string
+		wantStage Stage
+	}{
+		{name: "Session", match: "cookie-warning", method: "GET", wantStage: StageSession},
+		{name: "Upload", match: "/images", method: "POST", wantStage: StageUpload},
+		{name: "Apply", match: "/categories/faces/fat_maker", method: "POST", wantStage: StageApply},
+		{name: "Result page", match: "/results/", method: "GET", wantStage: StageResultPage},
+		{name: "Download", match: "example.com/result.jpg", method: "GET", wantStage: StageDownload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newEffectTransport(t, "faces/fat_maker", nil)
+			roundTrip := transport.RoundTripFunc
+			transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+				if req.Method == tt.method && strings.Contains(req.URL.String(), tt.match) {
+					if req.Body != nil {
+						io.Copy(io.Discard, req.Body)
+					}
+					return &http.Response{
+						Status:     "503 Service Unavailable",
+						StatusCode: http.StatusServiceUnavailable,
+						Body:       io.NopCloser(strings.NewReader("maintenance " + strings.Repeat("x", 2*maxErrorBodySize))),
+					}, nil
+				}
+				return roundTrip(req)
+			}
+
+			client := &PhotoFuniaClient{logger: &MockLogger{}, client: &http.Client{Transport: transport}}
+
+			_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+
+			var stageErr *Error
+			if !errors.As(err, &stageErr) {
+				t.Fatalf("ApplyEffect() error = %v, want *Error", err)
+			}
+			if stageErr.Stage != tt.wantStage {
+				t.Errorf("Stage = %q, want %q", stageErr.Stage, tt.wantStage)
+			}
+			if stageErr.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("StatusCode = %d, want %d", stageErr.StatusCode, http.StatusServiceUnavailable)
+			}
+			if stageErr.Effect != "fatify" {
+				t.Errorf("Effect = %q, want %q", stageErr.Effect, "fatify")
+			}
+			if !strings.HasPrefix(stageErr.Body, "maintenance") || len(stageErr.Body) != maxErrorBodySize {
+				t.Errorf("Body has length %d, want truncated body of length %d", len(stageErr.Body), maxErrorBodySize)
+			}
+			if !strings.Contains(err.Error(), "server returned non-OK status") {
+				t.Errorf("error = %q, want it to mention the status", err.Error())
+			}
+			if got := errors.Is(err, ErrSessionUnavailable); got != (tt.wantStage == StageSession) {
+				t.Errorf("errors.Is(err, ErrSessionUnavailable) = %v", got)
+			}
+		})
+	}
+}
+
+func TestDiscoveryErrors(t *testing.T) {
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: newFixtureTransport(t)},
+	}
+
+	_, err := client.EffectSchema(context.Background(), "faces/missing_effect")
+
+	var stageErr *Error
+	if !errors.As(err, &stageErr) {
+		t.Fatalf("EffectSchema() error = %v, want *Error", err)
+	}
+	if stageErr.Stage != StageDiscovery || stageErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Stage = %q, StatusCode = %d, want %q and 404", stageErr.Stage, stageErr.StatusCode, StageDiscovery)
+	}
+	if stageErr.Checkpoint != nil {
+		t.Errorf("Checkpoint = %+v, want nil", stageErr.Checkpoint)
+	}
+}
+
+func TestErrSessionUnavailable(t *testing.T) {
+	transport := &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+		},
+	}
+	client := &PhotoFuniaClient{logger: &MockLogger{}, client: &http.Client{Transport: transport}}
+
+	_, err := client.Upload(context.Background(), io.NopCloser(bytes.NewReader([]byte("fake-image-data"))))
+	if !errors.Is(err, ErrSessionUnavailable) {
+		t.Fatalf("Upload() error = %v, want ErrSessionUnavailable", err)
+	}
+	if !strings.Contains(err.Error(), "PHPSESSID cookie not found") {
+		t.Errorf("error = %q, want it to mention the missing cookie", err.Error())
+	}
+}
+
+func TestErrNoResultImage(t *testing.T) {
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" && strings.Contains(req.URL.String(), "/results/") {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("<html><body>Something went wrong</body></html>")),
+			}, nil
+		}
+		return roundTrip(req)
+	}
+	client := &PhotoFuniaClient{logger: &MockLogger{}, client: &http.Client{Transport: transport}}
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if !errors.Is(err, ErrNoResultImage) {
+		t.Fatalf("ApplyEffect() error = %v, want ErrNoResultImage", err)
+	}
+
+	var stageErr *Error
+	if !errors.As(err, &stageErr) || stageErr.Stage != StageResultPage || !strings.Contains(stageErr.Body, "Something went wrong") {
+		t.Errorf("error = %#v, want result page error with the page body", stageErr)
+	}
+}
+
+func TestErrEmptyImageKey(t *testing.T) {
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "/images") {
+			io.Copy(io.Discard, req.Body)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"response":{"key":""}}`)),
+			}, nil
+		}
+		return roundTrip(req)
+	}
+	client := &PhotoFuniaClient{logger: &MockLogger{}, client: &http.Client{Transport: transport}}
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if !errors.Is(err, ErrEmptyImageKey) {
+		t.Errorf("ApplyEffect() error = %v, want ErrEmptyImageKey", err)
+	}
+
+	_, err = client.Upload(context.Background(), io.NopCloser(bytes.NewReader([]byte("fake-image-data"))))
+	if !errors.Is(err, ErrEmptyImageKey) {
+		t.Errorf("Upload() error = %v, want ErrEmptyImageKey", err)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := &Error{Stage: StageApply, Effect: "fatify", Err: errors.New("server returned non-OK status: 500 Internal Server Error")}
+	if got, want := err.Error(), "photofunia fatify apply: server returned non-OK status: 500 Internal Server Error"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	err.Effect = ""
+	if got, want := err.Error(), "photofunia apply: server returned non-OK status: 500 Internal Server Error"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,480 @@
+package photofunia
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultHARBodySize is the default number of bytes of each request and
+// response body kept by a HARRecorder.
+const DefaultHARBodySize = 64 << 10
+
+// redactedValue replaces PHPSESSID values in HAR files.
+const redactedValue = "REDACTED"
+
+var sessionCookiePattern = regexp.MustCompile(`PHPSESSID=([^;,\s]*)`)
+
+// HARRecorder records the HTTP traffic of the clients it is attached to in
+// the HTTP Archive (HAR) 1.2 format, for attaching to bug reports.
+// Headers, timings and the first MaxBodySize bytes of every body are kept.
+// PHPSESSID values are redacted wherever they appear.
+//
+// Attach it with WithHARRecorder. It is safe for concurrent use.
+type HARRecorder struct {
+	// MaxBodySize is the number of bytes of each body kept in the archive.
+	// Longer bodies are truncated. It defaults to DefaultHARBodySize.
+	MaxBodySize int
+
+	mu       sync.Mutex
+	entries  []harEntry
+	sessions map[string]bool
+}
+
+// NewHARRecorder returns an empty HARRecorder.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{MaxBodySize: DefaultHARBodySize}
+}
+
+// WithHARRecorder records the traffic of the client in recorder.
+// The recorder wraps the current transport, so it should be applied after
+// WithHTTPClient and WithTransport.
+// Returns a new client recording its traffic.
+func (c *PhotoFuniaClient) WithHARRecorder(recorder *HARRecorder) *PhotoFuniaClient {
+	return c.With(WithHARRecorder(recorder))
+}
+
+// Transport returns an http.RoundTripper that records the exchanges made
+// through next, or http.DefaultTransport if next is nil.
+func (h *HARRecorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &harTransport{recorder: h, next: next}
+}
+
+// Len returns the number of exchanges recorded so far.
+func (h *HARRecorder) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.entries)
+}
+
+// Reset discards the exchanges recorded so far.
+func (h *HARRecorder) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = nil
+}
+
+// WriteTo writes the exchanges recorded so far to w as a HAR file.
+func (h *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	entries := make([]harEntry, len(h.entries))
+	for i, entry := range h.entries {
+		entries[i] = h.redactEntry(entry)
+	}
+	h.mu.Unlock()
+
+	archive := harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "photofunia", Version: "1"},
+		Entries: entries,
+	}}
+
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// Save writes the exchanges recorded so far to a HAR file at path.
+func (h *HARRecorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (h *HARRecorder) maxBodySize() int {
+	if h.MaxBodySize <= 0 {
+		return DefaultHARBodySize
+	}
+	return h.MaxBodySize
+}
+
+func (h *HARRecorder) add(entry harEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.sessions == nil {
+		h.sessions = make(map[string]bool)
+	}
+	for _, headers := range [][]harNameValue{entry.Request.Headers, entry.Response.Headers} {
+		for _, header := range headers {
+			for _, match := range sessionCookiePattern.FindAllStringSubmatch(header.Value, -1) {
+				if match[1] != "" {
+					h.sessions[match[1]] = true
+				}
+			}
+		}
+	}
+
+	h.entries = append(h.entries, entry)
+}
+
+// redactEntry returns a copy of entry with every known PHPSESSID value redacted.
+// It must be called with h.mu held.
+func (h *HARRecorder) redactEntry(entry harEntry) harEntry {
+	redact := func(s string) string {
+		s = sessionCookiePattern.ReplaceAllString(s, "PHPSESSID="+redactedValue)
+		for session := range h.sessions {
+			s = strings.ReplaceAll(s, session, redactedValue)
+		}
+		return s
+	}
+	redactAll := func(values []harNameValue) []harNameValue {
+		redacted := make([]harNameValue, len(values))
+		for i, value := range values {
+			redacted[i] = harNameValue{Name: value.Name, Value: value.Value}
+			if value.Name == "PHPSESSID" {
+				redacted[i].Value = redactedValue
+			} else {
+				redacted[i].Value = redact(value.Value)
+			}
+		}
+		return redacted
+	}
+
+	entry.Request.URL = redact(entry.Request.URL)
+	entry.Request.Headers = redactAll(entry.Request.Headers)
+	entry.Request.Cookies = redactAll(entry.Request.Cookies)
+	entry.Request.QueryString = redactAll(entry.Request.QueryString)
+	if entry.Request.PostData != nil {
+		postData := *entry.Request.PostData
+		postData.Text = redact(postData.Text)
+		entry.Request.PostData = &postData
+	}
+
+	entry.Response.Headers = redactAll(entry.Response.Headers)
+	entry.Response.Cookies = redactAll(entry.Response.Cookies)
+	entry.Response.RedirectURL = redact(entry.Response.RedirectURL)
+	entry.Response.Content.Text = redact(entry.Response.Content.Text)
+
+	return entry
+}
+
+// harTransport records the exchanges made through next in recorder.
+type harTransport struct {
+	recorder *HARRecorder
+	next     http.RoundTripper
+}
+
+func (t *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	limit := t.recorder.maxBodySize()
+	started := time.Now()
+
+	var wroteNanos atomic.Int64
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { wroteNanos.Store(time.Now().UnixNano()) },
+	}))
+
+	reqBody := &harCapture{limit: limit}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &harReadCloser{Reader: io.TeeReader(req.Body, reqBody), Closer: req.Body}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	headersReceived := time.Now()
+	wrote := started
+	if nanos := wroteNanos.Load(); nanos != 0 {
+		wrote = time.Unix(0, nanos)
+	}
+
+	entry := harEntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Timings: harTimings{
+			Blocked: -1,
+			DNS:     -1,
+			Connect: -1,
+			Send:    milliseconds(wrote.Sub(started)),
+			Wait:    milliseconds(headersReceived.Sub(wrote)),
+			SSL:     -1,
+		},
+	}
+
+	if err != nil {
+		entry.Response = harResponse{
+			HTTPVersion: "HTTP/1.1",
+			Headers:     []harNameValue{},
+			Cookies:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+			Comment:     err.Error(),
+		}
+		entry.Request = newHARRequest(req, reqBody)
+		entry.Time = milliseconds(headersReceived.Sub(started))
+		t.recorder.add(entry)
+		return nil, err
+	}
+
+	respBody := &harCapture{limit: limit}
+	resp.Body = &harResponseBody{
+		Reader: io.TeeReader(resp.Body, respBody),
+		Closer: resp.Body,
+		done: func() {
+			entry.Request = newHARRequest(req, reqBody)
+			entry.Response = newHARResponse(resp, respBody)
+			entry.Timings.Receive = milliseconds(time.Since(headersReceived))
+			entry.Time = entry.Timings.Send + entry.Timings.Wait + entry.Timings.Receive
+			t.recorder.add(entry)
+		},
+	}
+
+	return resp, nil
+}
+
+// harCapture keeps the first limit bytes written to it and counts the rest.
+type harCapture struct {
+	limit int
+	buf   bytes.Buffer
+	size  int64
+}
+
+func (c *harCapture) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	if remaining := c.limit - c.buf.Len(); remaining > 0 {
+		c.buf.Write(p[:min(remaining, len(p))])
+	}
+	return len(p), nil
+}
+
+func (c *harCapture) truncated() bool {
+	return c.size > int64(c.buf.Len())
+}
+
+type harReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// harResponseBody calls done once the body has been read to the end or closed.
+type harResponseBody struct {
+	io.Reader
+	io.Closer
+	done func()
+	once sync.Once
+}
+
+func (b *harResponseBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *harResponseBody) Close() error {
+	err := b.Closer.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+func newHARRequest(req *http.Request, body *harCapture) harRequest {
+	request := harRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: "HTTP/1.1",
+		Headers:     harHeaders(req.Header),
+		Cookies:     []harNameValue{},
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    body.size,
+	}
+
+	for _, cookie := range req.Cookies() {
+		request.Cookies = append(request.Cookies, harNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			request.QueryString = append(request.QueryString, harNameValue{Name: name, Value: value})
+		}
+	}
+
+	if body.size > 0 {
+		text, _ := harBodyText(body)
+		request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     text,
+		}
+		if body.truncated() {
+			request.Comment = "body truncated"
+		}
+	}
+
+	return request
+}
+
+func newHARResponse(resp *http.Response, body *harCapture) harResponse {
+	response := harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Headers:     harHeaders(resp.Header),
+		Cookies:     []harNameValue{},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    body.size,
+		Content: harContent{
+			Size:     body.size,
+			MimeType: resp.Header.Get("Content-Type"),
+		},
+	}
+	if response.HTTPVersion == "" {
+		response.HTTPVersion = "HTTP/1.1"
+	}
+
+	for _, cookie := range resp.Cookies() {
+		response.Cookies = append(response.Cookies, harNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	response.Content.Text, response.Content.Encoding = harBodyText(body)
+	if body.truncated() {
+		response.Content.Comment = "body truncated"
+	}
+
+	return response
+}
+
+// harBodyText returns the captured body as text, or as base64 with the
+// "base64" encoding when it is not valid UTF-8.
+func harBodyText(body *harCapture) (string, string) {
+	data := body.buf.Bytes()
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+
+	// A truncated text body may end in the middle of a character.
+	if body.truncated() {
+		for cut := len(data) - 1; cut >= 0 && cut >= len(data)-utf8.UTFMax; cut-- {
+			if utf8.Valid(data[:cut]) {
+				return string(data[:cut]), ""
+			}
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+	Comment     string         `json:"comment,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
@@ -0,0 +1,156 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func decodeHAR(t *testing.T, recorder *HARRecorder) (harFile, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if _, err := recorder.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	var archive harFile
+	if err := json.Unmarshal(buf.Bytes(), &archive); err != nil {
+		t.Fatalf("failed to decode HAR: %v", err)
+	}
+	return archive, buf.String()
+}
+
+func TestHARRecorder(t *testing.T) {
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if req.Body != nil {
+			body, _ := io.ReadAll(req.Body)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		return roundTrip(req)
+	}
+
+	recorder := NewHARRecorder()
+	client := NewClient(WithTransport(transport), WithHARRecorder(recorder))
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+
+	archive, text := decodeHAR(t, recorder)
+
+	if archive.Log.Version != "1.2" {
+		t.Errorf("version = %q, want %q", archive.Log.Version, "1.2")
+	}
+	if len(archive.Log.Entries) != 5 || recorder.Len() != 5 {
+		t.Fatalf("recorded %d entries, want 5 (session, upload, apply, result page, image)", len(archive.Log.Entries))
+	}
+	if strings.Contains(text, "test-session-id") {
+		t.Error("HAR contains the PHPSESSID")
+	}
+
+	upload := archive.Log.Entries[1]
+	if upload.Request.Method != "POST" || !strings.Contains(upload.Request.URL, "/images?server=1") {
+		t.Errorf("second entry = %s %s, want the upload", upload.Request.Method, upload.Request.URL)
+	}
+	if upload.Request.PostData == nil || !strings.Contains(upload.Request.PostData.Text, "fake-image-data") {
+		t.Errorf("upload postData = %+v, want the multipart body", upload.Request.PostData)
+	}
+	if upload.Response.Status != http.StatusOK || !strings.Contains(upload.Response.Content.Text, "test-image-key") {
+		t.Errorf("upload response = %d %q", upload.Response.Status, upload.Response.Content.Text)
+	}
+
+	for _, cookie := range upload.Request.Cookies {
+		if cookie.Name == "PHPSESSID" && cookie.Value != redactedValue {
+			t.Errorf("PHPSESSID cookie = %q, want %q", cookie.Value, redactedValue)
+		}
+	}
+
+	image := archive.Log.Entries[4]
+	if image.Response.Content.Text != "fake-image-data" || image.Response.Content.Size != int64(len("fake-image-data")) {
+		t.Errorf("image content = %+v", image.Response.Content)
+	}
+	if image.Timings.Send < 0 || image.Timings.Wait < 0 || image.Timings.Receive < 0 {
+		t.Errorf("timings = %+v, want non-negative send, wait and receive", image.Timings)
+	}
+}
+
+func TestHARRecorderTruncatesBodies(t *testing.T) {
+	data := append([]byte{0x89, 'P', 'N', 'G', 0xff}, bytes.Repeat([]byte{0xfe}, 100)...)
+	transport := &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"image/png"}},
+				Body:       io.NopCloser(bytes.NewReader(data)),
+			}, nil
+		},
+	}
+
+	recorder := NewHARRecorder()
+	recorder.MaxBodySize = 5
+	client := &http.Client{Transport: recorder.Transport(transport)}
+
+	resp, err := client.Get("https://example.com/result.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !bytes.Equal(body, data) {
+		t.Error("recording changed the response body")
+	}
+
+	archive, _ := decodeHAR(t, recorder)
+	if len(archive.Log.Entries) != 1 {
+		t.Fatalf("recorded %d entries, want 1", len(archive.Log.Entries))
+	}
+
+	content := archive.Log.Entries[0].Response.Content
+	if content.Encoding != "base64" || content.Text != "iVBOR/8=" {
+		t.Errorf("content = %s %q, want base64 of the first 5 bytes", content.Encoding, content.Text)
+	}
+	if content.Size != int64(len(data)) || content.Comment != "body truncated" {
+		t.Errorf("content size = %d, comment %q, want %d and truncated", content.Size, content.Comment, len(data))
+	}
+	if content.MimeType != "image/png" {
+		t.Errorf("mimeType = %q, want %q", content.MimeType, "image/png")
+	}
+}
+
+func TestHARRecorderRecordsErrors(t *testing.T) {
+	transport := &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		},
+	}
+
+	recorder := NewHARRecorder()
+	client := NewClient(WithTransport(transport), WithHARRecorder(recorder))
+
+	if _, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil); err == nil {
+		t.Fatal("ApplyEffect() succeeded, want error")
+	}
+
+	archive, _ := decodeHAR(t, recorder)
+	if len(archive.Log.Entries) != 1 {
+		t.Fatalf("recorded %d entries, want 1", len(archive.Log.Entries))
+	}
+	if comment := archive.Log.Entries[0].Response.Comment; !strings.Contains(comment, "connection refused") {
+		t.Errorf("response comment = %q, want the transport error", comment)
+	}
+
+	recorder.Reset()
+	if recorder.Len() != 0 {
+		t.Errorf("Len() after Reset() = %d, want 0", recorder.Len())
+	}
+}
@@ -1,30 +1,80 @@
 package photofunia
 
+import "time"
+
+// UploadResult describes an image uploaded to PhotoFunia, as returned by the server.
+type UploadResult struct {
+	// Key identifies the uploaded image on the server.
+	Key string `json:"key"`
+
+	// Server is the number of the server that stores the image.
+	Server int `json:"server"`
+
+	// Existed reports whether the server already had an identical image.
+	Existed bool `json:"existed"`
+
+	// Expiry is the Unix time, in seconds, at which the server discards the image.
+	Expiry int64 `json:"expiry"`
+
+	// Created is the Unix time, in seconds, at which the image was stored.
+	Created int64 `json:"created"`
+
+	// Lifetime is the number of seconds the server keeps the image.
+	Lifetime int `json:"lifetime"`
+
+	// Image holds the URLs and sizes of the stored versions of the image.
+	Image UploadedImageVersions `json:"image"`
+
+	// Sid is the server-side session identifier the image belongs to.
+	Sid string `json:"sid"`
+}
+
+// UploadedImageVersions holds the versions of an uploaded image stored by the server.
+type UploadedImageVersions struct {
+	// Highres is the full resolution version used to apply effects.
+	Highres ImageVersion `json:"highres"`
+
+	// Preview is a reduced version shown while editing.
+	Preview ImageVersion `json:"preview"`
+
+	// Thumb is a small thumbnail version.
+	Thumb ImageVersion `json:"thumb"`
+}
+
+// ImageVersion is a single stored version of an uploaded image.
+type ImageVersion struct {
+	// URL is the address the version can be downloaded from.
+	URL string `json:"url"`
+
+	// Width is the width of the version in pixels.
+	Width int `json:"width"`
+
+	// Height is the height of the version in pixels.
+	Height int `json:"height"`
+}
+
+// ExpiryTime returns Expiry as a time.Time, or the zero time if it is not set.
+func (r *UploadResult) ExpiryTime() time.Time {
+	return unixTime(r.Expiry)
+}
+
+// CreatedTime returns Created as a time.Time, or the zero time if it is not set.
+func (r *UploadResult) CreatedTime() time.Time {
+	return unixTime(r.Created)
+}
+
+// LifetimeDuration returns Lifetime as a time.Duration.
+func (r *UploadResult) LifetimeDuration() time.Duration {
+	return time.Duration(r.Lifetime) * time.Second
+}
+
+func unixTime(seconds int64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
 type photoFuniaResponse struct {
-	Response struct {
-		Key      string `json:"key"`
-		Server   int    `json:"server"`
-		Existed  bool   `json:"existed"`
-		Expiry   int64  `json:"expiry"`
-		Created  int64  `json:"created"`
-		Lifetime int    `json:"lifetime"`
-		Image    struct {
-			Highres struct {
-				URL    string `json:"url"`
-				Width  int    `json:"width"`
-				Height int    `json:"height"`
-			} `json:"highres"`
-			Preview struct {
-				URL    string `json:"url"`
-				Width  int    `json:"width"`
-				Height int    `json:"height"`
-			} `json:"preview"`
-			Thumb struct {
-				URL    string `json:"url"`
-				Width  int    `json:"width"`
-				Height int    `json:"height"`
-			} `json:"thumb"`
-		} `json:"image"`
-		Sid string `json:"sid"`
-	} `json:"response"`
+	Response UploadResult `json:"response"`
 }
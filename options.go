@@ -0,0 +1,172 @@
+package photofunia
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures a PhotoFuniaClient created with NewClient or
+// derived with PhotoFuniaClient.With.
+type Option func(*PhotoFuniaClient)
+
+// NewClient creates a new PhotoFuniaClient configured with the given options.
+// Without options, the client uses a no-op logger, DefaultTimeout,
+// DefaultBaseURL and DefaultUserAgent, and generates its own PHPSESSID.
+func NewClient(opts ...Option) *PhotoFuniaClient {
+	c := &PhotoFuniaClient{
+		logger:    NoopLogger{},
+		client:    &http.Client{Timeout: DefaultTimeout},
+		timeout:   DefaultTimeout,
+		baseURL:   DefaultBaseURL,
+		userAgent: DefaultUserAgent,
+	}
+	c.session.Store(&sessionState{})
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// With returns a copy of the client with the given options applied.
+// The original client is not modified. The copy starts out with the current
+// PHPSESSID of the client, but obtains new ones on its own.
+func (c *PhotoFuniaClient) With(opts ...Option) *PhotoFuniaClient {
+	newClient := &PhotoFuniaClient{
+		logger:        c.logger,
+		client:        c.client,
+		timeout:       c.timeout,
+		maxResultSize: c.maxResultSize,
+		baseURL:       c.baseURL,
+		userAgent:     c.userAgent,
+		sessionSource: c.sessionSource,
+		retryPolicy:   c.retryPolicy,
+	}
+	newClient.session.Store(&sessionState{id: c.SessionID()})
+	for _, opt := range opts {
+		opt(newClient)
+	}
+	return newClient
+}
+
+// WithLogger sets the logger used by the client.
+func WithLogger(logger Logger) Option {
+	return func(c *PhotoFuniaClient) {
+		c.logger = logger
+	}
+}
+
+// WithHTTPClient sets the http.Client used for all requests, for example to
+// route traffic through a proxy or add instrumentation. The client's own
+// Timeout is used as is. A nil httpClient keeps the current client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *PhotoFuniaClient) {
+		if httpClient == nil {
+			return
+		}
+		c.client = httpClient
+		c.timeout = httpClient.Timeout
+	}
+}
+
+// WithTransport sets the http.RoundTripper used for all requests, keeping
+// the current timeout and other http.Client settings.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *PhotoFuniaClient) {
+		c.client = c.httpClient()
+		c.client.Transport = transport
+	}
+}
+
+// WithTimeout sets the timeout for HTTP requests.
+// The transport and other settings of the current http.Client are kept.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *PhotoFuniaClient) {
+		c.timeout = timeout
+		c.client = c.httpClient()
+		c.client.Timeout = timeout
+	}
+}
+
+// WithMaxResultSize limits the size in bytes of downloaded result images.
+// Downloads exceeding the limit fail with ErrResultTooLarge. A limit of 0,
+// the default, disables the check.
+func WithMaxResultSize(maxSize int64) Option {
+	return func(c *PhotoFuniaClient) {
+		c.maxResultSize = maxSize
+	}
+}
+
+// WithBaseURL sets the URL of the PhotoFunia site the client talks to,
+// such as a mirror or a local stand-in server. It defaults to DefaultBaseURL.
+// Every request, including session, upload, effect and discovery requests,
+// and the Origin and Referer headers use it.
+func WithBaseURL(baseURL string) Option {
+	return func(c *PhotoFuniaClient) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+// It defaults to DefaultUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return func(c *PhotoFuniaClient) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed stages.
+// By default, failed stages are not retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *PhotoFuniaClient) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithSessionSource sets where the client gets its PHPSESSID from.
+// By default, the client requests a new session from PhotoFunia.
+func WithSessionSource(source SessionSource) Option {
+	return func(c *PhotoFuniaClient) {
+		c.sessionSource = source
+	}
+}
+
+// withSessionID makes the client start out with the given PHPSESSID.
+func withSessionID(sessID string) Option {
+	return func(c *PhotoFuniaClient) {
+		c.session.Store(&sessionState{id: sessID})
+	}
+}
+
+// SessionSource provides PHPSESSID values to a client, for example from a
+// pool of sessions shared between processes.
+type SessionSource interface {
+	// Session returns the PHPSESSID the client should use.
+	Session(ctx context.Context) (string, error)
+}
+
+// SessionFunc is an adapter to use an ordinary function as a SessionSource.
+type SessionFunc func(ctx context.Context) (string, error)
+
+// Session calls f(ctx).
+func (f SessionFunc) Session(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticSession returns a SessionSource that always provides the given PHPSESSID.
+func StaticSession(sessID string) SessionSource {
+	return SessionFunc(func(ctx context.Context) (string, error) {
+		return sessID, nil
+	})
+}
+
+// WithHARRecorder records the traffic of the client in recorder.
+// The recorder wraps the current transport, so it should be applied after
+// WithHTTPClient and WithTransport.
+func WithHARRecorder(recorder *HARRecorder) Option {
+	return func(c *PhotoFuniaClient) {
+		c.client = c.httpClient()
+		c.client.Transport = recorder.Transport(c.client.Transport)
+	}
+}
@@ -0,0 +1,242 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewClientDefaults(t *testing.T) {
+	client := NewClient()
+
+	if _, ok := client.logger.(NoopLogger); !ok {
+		t.Errorf("logger = %T, want NoopLogger", client.logger)
+	}
+	if client.client == nil || client.client.Timeout != DefaultTimeout {
+		t.Errorf("http.Client = %+v, want timeout %v", client.client, DefaultTimeout)
+	}
+	if client.baseURL != DefaultBaseURL {
+		t.Errorf("baseURL = %q, want %q", client.baseURL, DefaultBaseURL)
+	}
+	if client.userAgent != DefaultUserAgent {
+		t.Errorf("userAgent = %q, want %q", client.userAgent, DefaultUserAgent)
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	logger := &MockLogger{}
+	transport := &MockTransport{}
+
+	client := NewClient(
+		WithLogger(logger),
+		WithTransport(transport),
+		WithTimeout(time.Minute),
+		WithMaxResultSize(1024),
+		WithBaseURL("http://localhost:8080"),
+		WithUserAgent("test-agent"),
+	)
+
+	if client.logger != logger {
+		t.Errorf("logger = %v, want %v", client.logger, logger)
+	}
+	if client.client.Transport != transport {
+		t.Error("transport was not set")
+	}
+	if client.client.Timeout != time.Minute || client.timeout != time.Minute {
+		t.Errorf("timeout = %v, want %v", client.client.Timeout, time.Minute)
+	}
+	if client.maxResultSize != 1024 {
+		t.Errorf("maxResultSize = %d, want 1024", client.maxResultSize)
+	}
+	if client.baseURL != "http://localhost:8080" {
+		t.Errorf("baseURL = %q, want %q", client.baseURL, "http://localhost:8080")
+	}
+	if client.userAgent != "test-agent" {
+		t.Errorf("userAgent = %q, want %q", client.userAgent, "test-agent")
+	}
+}
+
+func TestWithDoesNotModifyOriginal(t *testing.T) {
+	original := NewClient()
+	client := original.With(WithUserAgent("test-agent"), WithTimeout(time.Second))
+
+	if original.userAgent != DefaultUserAgent {
+		t.Errorf("original userAgent = %q, want %q", original.userAgent, DefaultUserAgent)
+	}
+	if original.client.Timeout != DefaultTimeout {
+		t.Errorf("original timeout = %v, want %v", original.client.Timeout, DefaultTimeout)
+	}
+	if client.userAgent != "test-agent" || client.client.Timeout != time.Second {
+		t.Errorf("With() did not apply options: userAgent %q, timeout %v", client.userAgent, client.client.Timeout)
+	}
+}
+
+func TestWithCopiesSettings(t *testing.T) {
+	original := NewClient(
+		WithLogger(&MockLogger{}),
+		WithTransport(&MockTransport{}),
+		WithMaxResultSize(1024),
+		WithBaseURL("http://localhost:8080"),
+		WithUserAgent("test-agent"),
+		WithSessionSource(StaticSession("test-session-id")),
+		WithRetryPolicy(testRetryPolicy()),
+	)
+	client := original.With()
+
+	if client.logger != original.logger || client.client != original.client || client.timeout != original.timeout ||
+		client.maxResultSize != original.maxResultSize || client.baseURL != original.baseURL ||
+		client.userAgent != original.userAgent || client.sessionSource == nil ||
+		client.retryPolicy.InitialBackoff != original.retryPolicy.InitialBackoff {
+		t.Errorf("With() = %+v, want the settings of %+v", client, original)
+	}
+	if client.sessionState() == original.sessionState() {
+		t.Error("With() shared the session state of the original client")
+	}
+}
+
+func TestLiteralClientSessionState(t *testing.T) {
+	client := &PhotoFuniaClient{}
+
+	states := make(chan *sessionState, 16)
+	for i := 0; i < cap(states); i++ {
+		go func() {
+			states <- client.sessionState()
+		}()
+	}
+
+	first := <-states
+	for i := 1; i < cap(states); i++ {
+		if s := <-states; s != first {
+			t.Fatal("sessionState() returned different states for the same client")
+		}
+	}
+}
+
+func TestClientUsesConfiguredSiteAndUserAgent(t *testing.T) {
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if !strings.Contains(req.URL.Path, "/results/") && req.URL.Host != "example.com" {
+			if req.URL.Host != "mirror.example.org" {
+				t.Errorf("request to %s, want host mirror.example.org", req.URL)
+			}
+			if origin := req.Header.Get("Origin"); origin != "https://mirror.example.org" {
+				t.Errorf("Origin = %q, want %q", origin, "https://mirror.example.org")
+			}
+		}
+		if agent := req.Header.Get("User-Agent"); agent != "test-agent" {
+			t.Errorf("User-Agent = %q, want %q", agent, "test-agent")
+		}
+		return roundTrip(req)
+	}
+
+	client := NewClient(
+		WithTransport(transport),
+		WithBaseURL("https://mirror.example.org"),
+		WithUserAgent("test-agent"),
+	)
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+}
+
+func TestSessionSource(t *testing.T) {
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "cookie-warning") {
+			t.Error("client requested a new session despite a session source")
+		}
+		if cookie := req.Header.Get("Cookie"); !strings.Contains(cookie, "PHPSESSID=pooled-session") && !strings.Contains(req.URL.String(), "example.com") {
+			t.Errorf("Cookie = %q, want pooled session", cookie)
+		}
+		return roundTrip(req)
+	}
+
+	client := NewClient(WithTransport(transport), WithSessionSource(StaticSession("pooled-session")))
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+	if client.SessionID() != "pooled-session" {
+		t.Errorf("PHPSESSID = %q, want %q", client.SessionID(), "pooled-session")
+	}
+}
+
+func TestSessionSourceError(t *testing.T) {
+	sourceErr := errors.New("pool exhausted")
+	client := NewClient(
+		WithTransport(newEffectTransport(t, "faces/fat_maker", nil)),
+		WithSessionSource(SessionFunc(func(ctx context.Context) (string, error) {
+			return "", sourceErr
+		})),
+	)
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if !errors.Is(err, sourceErr) {
+		t.Errorf("ApplyEffect() error = %v, want %v", err, sourceErr)
+	}
+}
+
+func TestBaseURLWithHTTPTestServer(t *testing.T) {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	checkOrigin := func(r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != server.URL {
+			t.Errorf("%s %s: Origin = %q, want %q", r.Method, r.URL.Path, origin, server.URL)
+		}
+	}
+	mux.HandleFunc("/cookie-warning", func(w http.ResponseWriter, r *http.Request) {
+		checkOrigin(r)
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "local-session"})
+	})
+	mux.HandleFunc("/images", func(w http.ResponseWriter, r *http.Request) {
+		checkOrigin(r)
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, `{"response":{"key":"local-key","server":1}}`)
+	})
+	mux.HandleFunc("/categories/faces/fat_maker", func(w http.ResponseWriter, r *http.Request) {
+		checkOrigin(r)
+		if referer := r.Header.Get("Referer"); referer != server.URL+"/categories/faces/fat_maker" {
+			t.Errorf("Referer = %q, want %q", referer, server.URL+"/categories/faces/fat_maker")
+		}
+		http.Redirect(w, r, "/results/local", http.StatusFound)
+	})
+	mux.HandleFunc("/results/local", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<html><body><img id="result-image" src="/output/local.jpg"></body></html>`)
+	})
+	mux.HandleFunc("/output/local.jpg", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "local-image-data")
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewPhotoFuniaClient().WithBaseURL(server.URL + "/")
+
+	result, err := client.ApplyEffectResult(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffectResult() error = %v", err)
+	}
+
+	if string(result.Data) != "local-image-data" {
+		t.Errorf("Data = %q, want %q", result.Data, "local-image-data")
+	}
+	if result.ResultURL != server.URL+"/results/local" {
+		t.Errorf("ResultURL = %q, want %q", result.ResultURL, server.URL+"/results/local")
+	}
+	if result.ImageURL != server.URL+"/output/local.jpg" {
+		t.Errorf("ImageURL = %q, want %q", result.ImageURL, server.URL+"/output/local.jpg")
+	}
+	if client.SessionID() != "local-session" {
+		t.Errorf("PHPSESSID = %q, want %q", client.SessionID(), "local-session")
+	}
+}
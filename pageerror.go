@@ -0,0 +1,141 @@
+package photofunia
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrNoFaceDetected reports that PhotoFunia could not find a face in the image.
+	ErrNoFaceDetected = errors.New("no face detected in the image")
+
+	// ErrImageTooSmall reports that the image is smaller than the effect accepts.
+	ErrImageTooSmall = errors.New("image is too small")
+
+	// ErrImageTooLarge reports that the image is larger than PhotoFunia accepts.
+	ErrImageTooLarge = errors.New("image is too large")
+
+	// ErrUnsupportedFormat reports that PhotoFunia could not read the image format.
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+
+	// ErrEffectRejected reports any other error message shown by PhotoFunia.
+	ErrEffectRejected = errors.New("effect rejected by PhotoFunia")
+)
+
+var blockPattern = regexp.MustCompile(`(?is)<(div|p|span|li)\s([^>]*)>`)
+
+// blockEndPatterns match the closing tag of each element matched by blockPattern.
+var blockEndPatterns = map[string]*regexp.Regexp{
+	"div":  regexp.MustCompile(`(?i)</div\s*>`),
+	"p":    regexp.MustCompile(`(?i)</p\s*>`),
+	"span": regexp.MustCompile(`(?i)</span\s*>`),
+	"li":   regexp.MustCompile(`(?i)</li\s*>`),
+}
+
+// errorClasses are the class name words marking an error message block.
+var errorClasses = map[string]bool{"error": true, "alert": true, "danger": true, "notice": true, "warning": true}
+
+// pageErrorKinds lists the keywords identifying each kind of page error,
+// checked in order. A message matches a kind if it contains every keyword
+// of one of its keyword sets.
+var pageErrorKinds = []struct {
+	err      error
+	keywords [][]string
+}{
+	{ErrNoFaceDetected, [][]string{{"no face"}, {"no faces"}, {"face", "not"}, {"face", "could"}, {"face", "unable"}, {"face", "fail"}}},
+	{ErrImageTooSmall, [][]string{{"too small"}, {"at least"}, {"minimum"}}},
+	{ErrImageTooLarge, [][]string{{"too large"}, {"too big"}, {"maximum"}, {"exceeds"}}},
+	{ErrUnsupportedFormat, [][]string{{"format"}, {"not supported"}, {"unsupported"}, {"not an image"}, {"invalid image"}}},
+}
+
+// PageError is an error message PhotoFunia showed instead of a result image,
+// such as a notice that no face was found in the photo.
+//
+// It matches one of ErrNoFaceDetected, ErrImageTooSmall, ErrImageTooLarge,
+// ErrUnsupportedFormat or ErrEffectRejected with errors.Is, as well as
+// ErrNoResultImage.
+type PageError struct {
+	// Message is the text of the message shown by PhotoFunia.
+	Message string
+
+	// Kind is the sentinel error the message was classified as.
+	Kind error
+}
+
+// Error implements the error interface.
+func (e *PageError) Error() string {
+	return "PhotoFunia reported an error: " + e.Message
+}
+
+// Unwrap returns the kind of the error and ErrNoResultImage.
+func (e *PageError) Unwrap() []error {
+	return []error{e.Kind, ErrNoResultImage}
+}
+
+// parsePageError returns the first error message block of a page,
+// or nil if the page has none.
+func parsePageError(htmlContent []byte) *PageError {
+	page := string(htmlContent)
+
+	for _, match := range blockPattern.FindAllStringSubmatchIndex(page, -1) {
+		tag, attrs := strings.ToLower(page[match[2]:match[3]]), page[match[4]:match[5]]
+		if !isErrorBlock(htmlAttr(attrs, "class")) {
+			continue
+		}
+
+		end := blockEndPatterns[tag].FindStringIndex(page[match[1]:])
+		if end == nil {
+			continue
+		}
+
+		message := htmlText(page[match[1] : match[1]+end[0]])
+		if message == "" {
+			continue
+		}
+		return &PageError{Message: message, Kind: classifyPageError(message)}
+	}
+	return nil
+}
+
+// isErrorBlock reports whether a class attribute marks an error message,
+// such as "alert alert-danger", ignoring cookie banners.
+func isErrorBlock(class string) bool {
+	words := strings.FieldsFunc(strings.ToLower(class), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	found := false
+	for _, word := range words {
+		if word == "cookie" || word == "cookies" {
+			return false
+		}
+		if errorClasses[word] {
+			found = true
+		}
+	}
+	return found
+}
+
+// classifyPageError returns the sentinel error matching a message.
+func classifyPageError(message string) error {
+	message = strings.ToLower(message)
+	for _, kind := range pageErrorKinds {
+		for _, keywords := range kind.keywords {
+			if containsAll(message, keywords) {
+				return kind.err
+			}
+		}
+	}
+	return ErrEffectRejected
+}
+
+func containsAll(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if !strings.Contains(s, substr) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParsePageError(t *testing.T) {
+	tests := []struct {
+		name        string
+		html        string
+		wantMessage string
+		wantKind    error
+	}{
+		{
+			name:        "No face",
+			html:        `<div class="container"><div class="alert alert-danger"><strong>Error:</strong> No faces were found in your photo.</div></div>`,
+			wantMessage: "Error: No faces were found in your photo.",
+			wantKind:    ErrNoFaceDetected,
+		},
+		{
+			name:        "Face not detected",
+			html:        `<p class="error-message">We could not detect a face on the image</p>`,
+			wantMessage: "We could not detect a face on the image",
+			wantKind:    ErrNoFaceDetected,
+		},
+		{
+			name:        "Too small",
+			html:        `<div class='notice'>The image is too small. It should be at least 200&times;200 pixels.</div>`,
+			wantMessage: "The image is too small. It should be at least 200×200 pixels.",
+			wantKind:    ErrImageTooSmall,
+		},
+		{
+			name:        "Too large",
+			html:        `<div class="error">File exceeds the maximum upload size</div>`,
+			wantMessage: "File exceeds the maximum upload size",
+			wantKind:    ErrImageTooLarge,
+		},
+		{
+			name:        "Unsupported format",
+			html:        `<span class="warning">Unsupported file format</span>`,
+			wantMessage: "Unsupported file format",
+			wantKind:    ErrUnsupportedFormat,
+		},
+		{
+			name:        "Unclassified",
+			html:        `<div class="alert">Please try again later</div>`,
+			wantMessage: "Please try again later",
+			wantKind:    ErrEffectRejected,
+		},
+		{
+			name:        "Cookie banner ignored",
+			html:        `<div class="cookie-notice">We use cookies</div><div class="alert">Something broke</div>`,
+			wantMessage: "Something broke",
+			wantKind:    ErrEffectRejected,
+		},
+		{
+			name: "No error block",
+			html: `<html><body><div class="result">Nothing here</div><div class="alert"></div></body></html>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pageErr := parsePageError([]byte(tt.html))
+			if tt.wantKind == nil {
+				if pageErr != nil {
+					t.Errorf("parsePageError() = %+v, want nil", pageErr)
+				}
+				return
+			}
+
+			if pageErr == nil {
+				t.Fatal("parsePageError() = nil, want error")
+			}
+			if pageErr.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", pageErr.Message, tt.wantMessage)
+			}
+			if !errors.Is(pageErr, tt.wantKind) {
+				t.Errorf("Kind = %v, want %v", pageErr.Kind, tt.wantKind)
+			}
+			if !errors.Is(pageErr, ErrNoResultImage) {
+				t.Error("PageError does not match ErrNoResultImage")
+			}
+		})
+	}
+}
+
+func TestApplyEffectPageError(t *testing.T) {
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" && strings.Contains(req.URL.String(), "/results/") {
+			htmlContent := `<html><body><div class="alert alert-danger">No face detected. Please upload a photo with a clearly visible face.</div></body></html>`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(htmlContent)),
+			}, nil
+		}
+		return roundTrip(req)
+	}
+	client := &PhotoFuniaClient{logger: &MockLogger{}, client: &http.Client{Transport: transport}}
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if !errors.Is(err, ErrNoFaceDetected) {
+		t.Fatalf("ApplyEffect() error = %v, want ErrNoFaceDetected", err)
+	}
+
+	var pageErr *PageError
+	if !errors.As(err, &pageErr) {
+		t.Fatalf("ApplyEffect() error = %v, want *PageError", err)
+	}
+	if want := "No face detected. Please upload a photo with a clearly visible face."; pageErr.Message != want {
+		t.Errorf("Message = %q, want %q", pageErr.Message, want)
+	}
+
+	var stageErr *Error
+	if !errors.As(err, &stageErr) || stageErr.Stage != StageResultPage {
+		t.Errorf("error = %v, want result page *Error", err)
+	}
+}
@@ -14,22 +14,35 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	baseURL         = "https://photofunia.com"
+	// DefaultBaseURL is the URL of the PhotoFunia site used by default.
+	DefaultBaseURL = "https://photofunia.com"
+
+	// DefaultUserAgent is the User-Agent header sent by default.
+	DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36"
+
 	defaultBoundary = "----WebKitFormBoundaryL3VFyS6LkNI3s7UM"
-	userAgent       = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36"
 )
 
 // PhotoFuniaClient is a client for the PhotoFunia service.
 // It handles session management and HTTP requests to apply various effects to images.
+//
+// A PhotoFuniaClient is safe for concurrent use by multiple goroutines. Its
+// PHPSESSID is shared by all of them, and obtained once for all of them.
 type PhotoFuniaClient struct {
-	PHPSESSID string
-	logger    Logger
-	client    *http.Client
-	timeout   time.Duration
+	session       atomic.Pointer[sessionState]
+	logger        Logger
+	client        *http.Client
+	timeout       time.Duration
+	maxResultSize int64
+	baseURL       string
+	userAgent     string
+	sessionSource SessionSource
+	retryPolicy   RetryPolicy
 }
 
 // DefaultTimeout is the default timeout for HTTP requests.
@@ -37,27 +50,79 @@ const DefaultTimeout = 30 * time.Second
 
 // NewPhotoFuniaClient creates a new PhotoFuniaClient with a no-op logger.
 // This is a convenience function for users who don't need logging.
+// It is equivalent to NewClient().
 func NewPhotoFuniaClient() *PhotoFuniaClient {
-	return NewPhotoFuniaClientWithLogger(NoopLogger{})
+	return NewClient()
 }
 
 // NewPhotoFuniaClientWithLogger creates a new PhotoFuniaClient with the provided logger.
 // This allows users to integrate the client with their own logging system.
+// It is equivalent to NewClient(WithLogger(logger)).
 func NewPhotoFuniaClientWithLogger(logger Logger) *PhotoFuniaClient {
-	return &PhotoFuniaClient{
-		logger:  logger,
-		client:  &http.Client{Timeout: DefaultTimeout},
-		timeout: DefaultTimeout,
-	}
+	return NewClient(WithLogger(logger))
 }
 
 // WithTimeout sets a custom timeout for HTTP requests.
+// The transport and other settings of the current http.Client are kept.
 // Returns a new client with the specified timeout.
 func (c *PhotoFuniaClient) WithTimeout(timeout time.Duration) *PhotoFuniaClient {
-	newClient := *c
-	newClient.timeout = timeout
-	newClient.client = &http.Client{Timeout: timeout}
-	return &newClient
+	return c.With(WithTimeout(timeout))
+}
+
+// WithHTTPClient sets the http.Client used for all requests, for example to
+// route traffic through a proxy or add instrumentation. The client's own
+// Timeout is used as is. A nil httpClient keeps the current client.
+// Returns a new client using the provided http.Client.
+func (c *PhotoFuniaClient) WithHTTPClient(httpClient *http.Client) *PhotoFuniaClient {
+	return c.With(WithHTTPClient(httpClient))
+}
+
+// WithTransport sets the http.RoundTripper used for all requests, keeping
+// the current timeout and other http.Client settings.
+// Returns a new client using the provided transport.
+func (c *PhotoFuniaClient) WithTransport(transport http.RoundTripper) *PhotoFuniaClient {
+	return c.With(WithTransport(transport))
+}
+
+// httpClient returns a copy of the current http.Client, so that changing
+// it does not affect other PhotoFuniaClient values sharing it.
+func (c *PhotoFuniaClient) httpClient() *http.Client {
+	if c.client == nil {
+		return &http.Client{Timeout: c.timeout}
+	}
+	httpClient := *c.client
+	return &httpClient
+}
+
+// WithMaxResultSize limits the size in bytes of downloaded result images.
+// Downloads exceeding the limit fail with ErrResultTooLarge. A limit of 0,
+// the default, disables the check.
+// Returns a new client with the specified limit.
+func (c *PhotoFuniaClient) WithMaxResultSize(maxSize int64) *PhotoFuniaClient {
+	return c.With(WithMaxResultSize(maxSize))
+}
+
+// WithBaseURL sets the URL of the PhotoFunia site the client talks to,
+// such as a mirror or a local httptest server.
+// Returns a new client using the provided base URL.
+func (c *PhotoFuniaClient) WithBaseURL(baseURL string) *PhotoFuniaClient {
+	return c.With(WithBaseURL(baseURL))
+}
+
+// site returns the base URL the client talks to.
+func (c *PhotoFuniaClient) site() string {
+	if c.baseURL == "" {
+		return DefaultBaseURL
+	}
+	return c.baseURL
+}
+
+// agent returns the User-Agent header sent by the client.
+func (c *PhotoFuniaClient) agent() string {
+	if c.userAgent == "" {
+		return DefaultUserAgent
+	}
+	return c.userAgent
 }
 
 // FatifyWithContext applies the "fat maker" effect to the provided image with context support.
@@ -67,13 +132,17 @@ func (c *PhotoFuniaClient) WithTimeout(timeout time.Duration) *PhotoFuniaClient
 // The img parameter should be an io.ReadCloser containing the image data.
 // The function will close the reader when done.
 func (c *PhotoFuniaClient) FatifyWithContext(ctx context.Context, img io.ReadCloser) ([]byte, error) {
-	params := map[string]string{
-		"current-category": "faces",
-		"image:crop":       "0.0.961.1093",
-		"size":             "XXXXXL",
+	result, err := c.FatifyResult(ctx, img)
+	if err != nil {
+		return nil, err
 	}
+	return result.Data, nil
+}
 
-	return c.applyEffectWithContext(ctx, img, "faces/fat_maker", params, "fatify")
+// Fatify applies the "fat maker" effect to the provided image.
+// It is equivalent to calling FatifyWithContext with context.Background().
+func (c *PhotoFuniaClient) Fatify(img io.ReadCloser) ([]byte, error) {
+	return c.FatifyWithContext(context.Background(), img)
 }
 
 // ClownifyWithContext applies the clown effect to the provided image with context support.
@@ -85,73 +154,206 @@ func (c *PhotoFuniaClient) FatifyWithContext(ctx context.Context, img io.ReadClo
 //
 // The includeHat parameter determines whether a clown hat is added to the image.
 func (c *PhotoFuniaClient) ClownifyWithContext(ctx context.Context, img io.ReadCloser, includeHat bool) ([]byte, error) {
-	params := map[string]string{
-		"current-category": "all_effects",
-		"image:crop":       "0.0.961.1093",
+	result, err := c.ClownifyResult(ctx, img, includeHat)
+	if err != nil {
+		return nil, err
 	}
+	return result.Data, nil
+}
 
-	if includeHat {
-		params["hat"] = "on"
-	} else {
-		params["hat"] = "off"
-	}
+// Clownify applies the clown effect to the provided image.
+// It is equivalent to calling ClownifyWithContext with context.Background().
+func (c *PhotoFuniaClient) Clownify(img io.ReadCloser, includeHat bool) ([]byte, error) {
+	return c.ClownifyWithContext(context.Background(), img, includeHat)
+}
 
-	return c.applyEffectWithContext(ctx, img, "all_effects/clown", params, "clownify")
+func (c *PhotoFuniaClient) applyEffect(img io.ReadCloser, effectPath string, params map[string]string, imageField string, effectName string) ([]byte, error) {
+	return c.applyEffectWithContext(context.Background(), img, effectPath, params, imageField, effectName)
 }
 
-func (c *PhotoFuniaClient) applyEffect(img io.ReadCloser, effectPath string, params map[string]string, effectName string) ([]byte, error) {
-	return c.applyEffectWithContext(context.Background(), img, effectPath, params, effectName)
+func (c *PhotoFuniaClient) applyEffectWithContext(ctx context.Context, img io.ReadCloser, effectPath string, params map[string]string, imageField string, effectName string) ([]byte, error) {
+	result, err := c.runEffectWithContext(ctx, effectPath, params, map[string]io.ReadCloser{imageField: img}, []string{imageField}, effectName)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
 }
 
-func (c *PhotoFuniaClient) applyEffectWithContext(ctx context.Context, img io.ReadCloser, effectPath string, params map[string]string, effectName string) ([]byte, error) {
-	response, err := c.uploadImageWithContext(ctx, img)
+// runEffectWithContext runs the whole pipeline of an effect: it ensures a session,
+// uploads the given images, posts the effect form, reads the result page and
+// downloads the resulting image, timing each stage.
+//
+// The images are uploaded and their keys sent under their input names.
+// Keys of images uploaded earlier must already be set in params. The imageFields
+// parameter lists every image input of the effect, in order.
+func (c *PhotoFuniaClient) runEffectWithContext(ctx context.Context, effectPath string, params map[string]string, images map[string]io.ReadCloser, imageFields []string, effectName string) (*Result, error) {
+	return c.resumeWithContext(ctx, newCheckpoint(effectPath, params, imageFields, effectName), images)
+}
+
+// resumeWithContext runs the stages of the pipeline the checkpoint has not
+// completed yet, recording their progress in it, and downloads the result image.
+func (c *PhotoFuniaClient) resumeWithContext(ctx context.Context, cp *Checkpoint, images map[string]io.ReadCloser) (*Result, error) {
+	result, err := c.prepareResultWithContext(ctx, cp, images)
 	if err != nil {
 		return nil, err
 	}
 
-	imageKey := response.Response.Key
-	if imageKey == "" {
-		return nil, errors.New("image key is empty in the response")
+	start := time.Now()
+	var data []byte
+	var contentType string
+	err = c.withRetry(ctx, StageDownload, cp, func() error {
+		var err error
+		data, contentType, err = c.downloadImageWithContext(ctx, cp.ImageURL, cp.ResultURL)
+		return err
+	})
+	if err != nil {
+		return nil, cp.fail(err)
 	}
+	result.Timings.Download = time.Since(start)
 
-	c.logger.Info("got image key", Field{"key", imageKey})
+	result.setData(data, contentType)
+	return result, nil
+}
+
+// prepareResultWithContext runs every stage of the pipeline the checkpoint has
+// not completed yet except the image download, and returns a Result holding
+// the URL of the result image. Stages that were completed are skipped.
+func (c *PhotoFuniaClient) prepareResultWithContext(ctx context.Context, cp *Checkpoint, images map[string]io.ReadCloser) (*Result, error) {
+	result := &Result{}
+
+	start := time.Now()
+	sessID, err := c.sessionIDWithContext(ctx)
+	if err != nil {
+		for _, img := range images {
+			img.Close()
+		}
+		return nil, cp.fail(err)
+	}
+	if cp.SessionID == "" {
+		cp.SessionID = sessID
+		result.Timings.Session = time.Since(start)
+	}
+
+	if len(images) > 0 {
+		start = time.Now()
+		keys, err := c.uploadImagesWithContext(ctx, images)
+		for field, key := range keys {
+			cp.ImageKeys[field] = key
+		}
+		// Uploads replace an expired session on their own.
+		if id := c.sessionState().current(); id != "" {
+			cp.SessionID = id
+		}
+		if err != nil {
+			return nil, cp.fail(err)
+		}
+		result.Timings.Upload = time.Since(start)
+	}
+
+	if len(cp.ImageFields) > 0 {
+		result.ImageKeys = make(map[string]string, len(cp.ImageFields))
+		for _, field := range cp.ImageFields {
+			result.ImageKeys[field] = cp.ImageKeys[field]
+		}
+		result.ImageKey = cp.ImageKeys[cp.ImageFields[0]]
+	}
+
+	// A result page belongs to the session that applied the effect, so when
+	// the session expires before the page is read, the effect is applied again
+	// with a new session. A result page missing right after the effect was
+	// applied is taken as a sign of an expired session too.
+	for reapplied := false; ; reapplied = true {
+		applied := cp.ResultURL == ""
+		if applied {
+			start = time.Now()
+			params := cp.formParams()
+			err := c.withRetry(ctx, StageApply, cp, func() error {
+				var err error
+				cp.ResultURL, err = c.submitEffectWithContext(ctx, cp.Path, params, cp.Effect)
+				return err
+			})
+			if err != nil {
+				return nil, cp.fail(err)
+			}
+			result.Timings.Apply += time.Since(start)
+		}
+		result.ResultURL = cp.ResultURL
+
+		if cp.ImageURL != "" {
+			break
+		}
+
+		start = time.Now()
+		session := c.sessionState().current()
+		err := c.withRetry(ctx, StageResultPage, cp, func() error {
+			var err error
+			cp.ImageURL, err = c.getResultPageWithContext(ctx, cp.ResultURL)
+			return err
+		})
+		result.Timings.ResultPage += time.Since(start)
+		if err == nil {
+			break
+		}
+		expired := errors.Is(err, ErrSessionExpired) || applied && hasStatus(err, http.StatusNotFound)
+		if reapplied || !expired {
+			return nil, cp.fail(err)
+		}
+
+		c.logger.Info("session expired, generating new PHPSESSID", Field{"stage", string(StageResultPage)})
+		id, err := c.refreshSession(ctx, session)
+		if err != nil {
+			return nil, cp.fail(err)
+		}
+		cp.SessionID = id
+		cp.ResultURL = ""
+	}
+	result.ImageURL = cp.ImageURL
 
+	return result, nil
+}
+
+// submitEffectWithContext posts the effect form with the given parameters and
+// returns the URL of the result page. Image inputs must already be uploaded
+// and their keys set in params.
+func (c *PhotoFuniaClient) submitEffectWithContext(ctx context.Context, effectPath string, params map[string]string, effectName string) (string, error) {
 	var requestBody bytes.Buffer
 	writer := multipart.NewWriter(&requestBody)
 	writer.SetBoundary(defaultBoundary)
 
-	params["image"] = imageKey
-
 	for key, value := range params {
 		if err := writer.WriteField(key, value); err != nil {
-			return nil, fmt.Errorf("failed to write field %s: %w", key, err)
+			return "", stageError(StageApply, nil, fmt.Errorf("failed to write field %s: %w", key, err))
 		}
 	}
 
 	if err := writer.Close(); err != nil {
-		return nil, err
+		return "", stageError(StageApply, nil, err)
 	}
 
-	url := fmt.Sprintf("%s/categories/%s?server=1", baseURL, effectPath)
+	url := fmt.Sprintf("%s/categories/%s?server=1", c.site(), effectPath)
 	req, err := c.createRequestWithContext(ctx, "POST", url, &requestBody)
 	if err != nil {
-		return nil, err
+		return "", asStageError(StageApply, err)
 	}
 
 	req.Header.Set("Content-Type", "multipart/form-data; boundary="+defaultBoundary)
 
-	req.Header.Set("Referer", fmt.Sprintf("%s/categories/%s", baseURL, effectPath))
+	req.Header.Set("Referer", fmt.Sprintf("%s/categories/%s", c.site(), effectPath))
 
 	c.logger.Info(fmt.Sprintf("sending request to PhotoFunia %s effect", effectName))
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to perform request to PhotoFunia %s effect: %w", effectName, err)
+		return "", stageError(StageApply, nil, fmt.Errorf("failed to perform request to PhotoFunia %s effect: %w", effectName, err))
 	}
 	defer resp.Body.Close()
 
+	if sessionExpired(resp) {
+		return "", stageError(StageApply, resp, ErrSessionExpired)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned non-OK status: %s", resp.Status)
+		return "", statusError(StageApply, resp, "server returned non-OK status: %s")
 	}
 
 	c.logger.Info(fmt.Sprintf("successfully received response from PhotoFunia %s effect", effectName),
@@ -159,76 +361,138 @@ func (c *PhotoFuniaClient) applyEffectWithContext(ctx context.Context, img io.Re
 		Field{"contentLength", resp.ContentLength},
 		Field{"resultURL", resp.Request.URL.String()})
 
-	return c.getResultImageWithContext(ctx, resp.Request.URL.String())
+	return resp.Request.URL.String(), nil
+}
+
+// sessionIDWithContext returns the PHPSESSID of the client, obtaining one if
+// it does not have one yet. Concurrent callers share a single request for it.
+func (c *PhotoFuniaClient) sessionIDWithContext(ctx context.Context) (string, error) {
+	return c.sessionState().get(ctx, c.newSessionWithContext)
 }
 
-func (c *PhotoFuniaClient) generateSessIDWithContext(ctx context.Context) error {
+// newSessionWithContext obtains a new PHPSESSID, from the session source when
+// one is configured.
+func (c *PhotoFuniaClient) newSessionWithContext(ctx context.Context) (string, error) {
+	var sessID string
+	err := c.withRetry(ctx, StageSession, nil, func() error {
+		var err error
+		if c.sessionSource == nil {
+			sessID, err = c.generateSessIDWithContext(ctx)
+			return err
+		}
+
+		sessID, err = c.sessionSource.Session(ctx)
+		if err != nil {
+			return stageError(StageSession, nil, fmt.Errorf("failed to get PHPSESSID from session source: %w", err))
+		}
+		if sessID == "" {
+			return stageError(StageSession, nil, errors.New("session source returned an empty PHPSESSID"))
+		}
+		return nil
+	})
+	return sessID, err
+}
+
+func (c *PhotoFuniaClient) generateSessIDWithContext(ctx context.Context) (string, error) {
 	c.logger.Info("generating new PHPSESSID")
 
-	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/cookie-warning", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.site()+"/cookie-warning", nil)
 	if err != nil {
-		return fmt.Errorf("failed to create HTTP request for PHPSESSID: %w", err)
+		return "", stageError(StageSession, nil, fmt.Errorf("failed to create HTTP request for PHPSESSID: %w", err))
 	}
 
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 	req.Header.Set("Cache-Control", "max-age=0")
 	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Origin", baseURL)
-	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Origin", c.site())
+	req.Header.Set("User-Agent", c.agent())
 	req.Header.Set("Cookie", "accept_cookie=true")
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to perform request to PhotoFunia for PHPSESSID: %w", err)
+		return "", stageError(StageSession, nil, fmt.Errorf("failed to perform request to PhotoFunia for PHPSESSID: %w", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned non-OK status for PHPSESSID request: %s", resp.Status)
+		return "", statusError(StageSession, resp, "server returned non-OK status for PHPSESSID request: %s")
 	}
 
 	for _, cookie := range resp.Cookies() {
 		if cookie.Name == "PHPSESSID" {
-			c.PHPSESSID = cookie.Value
-			return nil
+			return cookie.Value, nil
 		}
 	}
 
-	return errors.New("PHPSESSID cookie not found in response")
+	return "", stageError(StageSession, resp, errors.New("PHPSESSID cookie not found in response"))
 }
 
-func (c *PhotoFuniaClient) getResultImageWithContext(ctx context.Context, resultURL string) ([]byte, error) {
+// getResultPageWithContext reads the result page and returns the URL of the result image.
+func (c *PhotoFuniaClient) getResultPageWithContext(ctx context.Context, resultURL string) (string, error) {
 	req, err := c.createRequestWithContext(ctx, "GET", resultURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request for result page: %w", err)
+		return "", asStageError(StageResultPage, fmt.Errorf("failed to create HTTP request for result page: %w", err))
 	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get result page: %w", err)
+		return "", stageError(StageResultPage, nil, fmt.Errorf("failed to get result page: %w", err))
 	}
 	defer resp.Body.Close()
 
+	if sessionExpired(resp) {
+		return "", stageError(StageResultPage, resp, ErrSessionExpired)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned non-OK status for result page: %s", resp.Status)
+		return "", statusError(StageResultPage, resp, "server returned non-OK status for result page: %s")
 	}
 
 	htmlContent, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read HTML content: %w", err)
+		return "", stageError(StageResultPage, nil, fmt.Errorf("failed to read HTML content: %w", err))
 	}
 
 	imageURL, err := extractImageURL(htmlContent)
 	if err != nil {
-		return nil, err
+		if pageErr := parsePageError(htmlContent); pageErr != nil {
+			err = pageErr
+		}
+		return "", &Error{Stage: StageResultPage, StatusCode: resp.StatusCode, Body: truncateBody(htmlContent), Err: err}
 	}
 
+	imageURL = resolveURL(resultURL, imageURL)
+
 	c.logger.Info("found image URL", Field{"url", imageURL})
+	return imageURL, nil
+}
+
+// downloadImageWithContext downloads the result image and returns its data
+// along with the Content-Type reported by the server.
+func (c *PhotoFuniaClient) downloadImageWithContext(ctx context.Context, imageURL, resultURL string) ([]byte, string, error) {
+	imgResp, err := c.openImageWithContext(ctx, imageURL, resultURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer imgResp.Body.Close()
+
+	imageData, err := io.ReadAll(imgResp.Body)
+	if err != nil {
+		return nil, "", stageError(StageDownload, nil, fmt.Errorf("failed to read image data: %w", err))
+	}
+
+	c.logger.Info("successfully downloaded image", Field{"url", imageURL}, Field{"size", len(imageData)})
+	return imageData, imgResp.Header.Get("Content-Type"), nil
+}
 
+// openImageWithContext requests the result image and returns the response
+// once its headers have been received. The body is limited to the client's
+// maximum result size and must be closed by the caller.
+func (c *PhotoFuniaClient) openImageWithContext(ctx context.Context, imageURL, resultURL string) (*http.Response, error) {
 	imgReq, err := c.createRequestWithContext(ctx, "GET", imageURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request for image: %w", err)
+		return nil, asStageError(StageDownload, fmt.Errorf("failed to create HTTP request for image: %w", err))
 	}
 
 	imgReq.Header.Set("Accept", "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8")
@@ -236,21 +500,28 @@ func (c *PhotoFuniaClient) getResultImageWithContext(ctx context.Context, result
 
 	imgResp, err := c.client.Do(imgReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download image: %w", err)
+		return nil, stageError(StageDownload, nil, fmt.Errorf("failed to download image: %w", err))
+	}
+
+	if sessionExpired(imgResp) {
+		defer imgResp.Body.Close()
+		return nil, stageError(StageDownload, imgResp, ErrSessionExpired)
 	}
-	defer imgResp.Body.Close()
 
 	if imgResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned non-OK status for image: %s", imgResp.Status)
+		defer imgResp.Body.Close()
+		return nil, statusError(StageDownload, imgResp, "server returned non-OK status for image: %s")
 	}
 
-	imageData, err := io.ReadAll(imgResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read image data: %w", err)
+	if c.maxResultSize > 0 {
+		if imgResp.ContentLength > c.maxResultSize {
+			imgResp.Body.Close()
+			return nil, &Error{Stage: StageDownload, StatusCode: imgResp.StatusCode, Err: fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrResultTooLarge, imgResp.ContentLength, c.maxResultSize)}
+		}
+		imgResp.Body = &limitedBody{body: imgResp.Body, remaining: c.maxResultSize}
 	}
 
-	c.logger.Info("successfully downloaded image", Field{"url", imageURL}, Field{"size", len(imageData)})
-	return imageData, nil
+	return imgResp, nil
 }
 
 func extractImageURL(htmlContent []byte) (string, error) {
@@ -260,71 +531,81 @@ func extractImageURL(htmlContent []byte) (string, error) {
 
 	imgTagIndex := bytes.Index(htmlContent, []byte(imgTagStart))
 	if imgTagIndex == -1 {
-		return "", errors.New("could not find result image in HTML")
+		return "", ErrNoResultImage
 	}
 
 	srcStartIndex := bytes.Index(htmlContent[imgTagIndex:], []byte(srcAttrStart))
 	if srcStartIndex == -1 {
-		return "", errors.New("could not find src attribute in image tag")
+		return "", fmt.Errorf("%w: could not find src attribute in image tag", ErrNoResultImage)
 	}
 	srcStartIndex += imgTagIndex + len(srcAttrStart)
 
 	srcEndIndex := bytes.Index(htmlContent[srcStartIndex:], []byte(srcAttrEnd))
 	if srcEndIndex == -1 {
-		return "", errors.New("could not find end of src attribute")
+		return "", fmt.Errorf("%w: could not find end of src attribute", ErrNoResultImage)
 	}
 	srcEndIndex += srcStartIndex
 
 	return string(htmlContent[srcStartIndex:srcEndIndex]), nil
 }
 
-func (c *PhotoFuniaClient) uploadImageWithContext(ctx context.Context, imageReader io.ReadCloser) (*photoFuniaResponse, error) {
-	defer imageReader.Close()
-
-	imageData, err := io.ReadAll(imageReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read image data: %w", err)
-	}
-
-	c.logger.Info("read image data", Field{"size", len(imageData)})
-
-	var requestBody bytes.Buffer
-	writer := multipart.NewWriter(&requestBody)
-
+// uploadImageWithContext uploads an image, streaming it into the multipart
+// request body through a pipe so that the image is never held in memory.
+// When the image size is known, the request is sent with a Content-Length.
+func (c *PhotoFuniaClient) uploadImageWithContext(ctx context.Context, imageReader io.ReadCloser) (*UploadResult, error) {
 	uploadBoundary := "----WebKitFormBoundaryx4CBHpJEw9pPEXE4"
-	writer.SetBoundary(uploadBoundary)
 
-	part, err := writer.CreateFormFile("image", "image.png")
+	pipeReader, pipeWriter := io.Pipe()
+
+	req, err := c.createRequestWithContext(ctx, "POST", c.site()+"/images?server=1", pipeReader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create form file: %w", err)
+		imageReader.Close()
+		return nil, asStageError(StageUpload, err)
 	}
+	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+uploadBoundary)
+	req.Header.Set("Referer", c.site()+"/categories/all_effects/clown")
 
-	if _, err = part.Write(imageData); err != nil {
-		return nil, fmt.Errorf("failed to write image data: %w", err)
+	if size, ok := imageSize(imageReader); ok {
+		req.ContentLength = multipartOverhead(uploadBoundary) + size
+		c.logger.Info("streaming image data", Field{"size", size})
+	} else {
+		c.logger.Info("streaming image data of unknown size")
 	}
 
-	if err = writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close writer: %w", err)
-	}
+	writeErr := make(chan error, 1)
+	go func() {
+		defer imageReader.Close()
 
-	req, err := c.createRequestWithContext(ctx, "POST", baseURL+"/images?server=1", &requestBody)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
-	req.Header.Set("Content-Type", "multipart/form-data; boundary="+uploadBoundary)
-	req.Header.Set("Referer", baseURL+"/categories/all_effects/clown")
+		err := writeMultipartImage(pipeWriter, uploadBoundary, imageReader)
+		pipeWriter.CloseWithError(err)
+		writeErr <- err
+	}()
 
 	c.logger.Info("sending request to PhotoFunia")
 
 	resp, err := c.client.Do(req)
+
+	// Stop the writer if the transport returned without consuming the whole body.
+	pipeReader.Close()
+	if bodyErr := <-writeErr; bodyErr != nil && !errors.Is(bodyErr, io.ErrClosedPipe) {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return nil, stageError(StageUpload, nil, bodyErr)
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf("failed to perform request to PhotoFunia: %w", err)
+		return nil, stageError(StageUpload, nil, fmt.Errorf("failed to perform request to PhotoFunia: %w", err))
 	}
 	defer resp.Body.Close()
 
+	if sessionExpired(resp) {
+		return nil, stageError(StageUpload, resp, ErrSessionExpired)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned non-OK status: %s", resp.Status)
+		return nil, statusError(StageUpload, resp, "server returned non-OK status: %s")
 	}
 
 	c.logger.Info("successfully received response from PhotoFunia",
@@ -333,10 +614,46 @@ func (c *PhotoFuniaClient) uploadImageWithContext(ctx context.Context, imageRead
 
 	var photoFuniaResp photoFuniaResponse
 	if err = json.NewDecoder(resp.Body).Decode(&photoFuniaResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, stageError(StageUpload, nil, fmt.Errorf("failed to decode response: %w", err))
+	}
+
+	return &photoFuniaResp.Response, nil
+}
+
+// writeMultipartImage writes the multipart upload body for an image to w.
+func writeMultipartImage(w io.Writer, boundary string, imageReader io.Reader) error {
+	writer := multipart.NewWriter(w)
+	writer.SetBoundary(boundary)
+
+	part, err := writer.CreateFormFile("image", "image.png")
+	if err != nil {
+		return fmt.Errorf("failed to create form file: %w", err)
+	}
+
+	if _, err = io.Copy(part, imageReader); err != nil {
+		return fmt.Errorf("failed to write image data: %w", err)
+	}
+
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
 	}
 
-	return &photoFuniaResp, nil
+	return nil
+}
+
+// multipartOverhead returns the number of bytes the multipart upload body
+// adds around the image data.
+func multipartOverhead(boundary string) int64 {
+	var counter countingWriter
+	writeMultipartImage(&counter, boundary, bytes.NewReader(nil))
+	return int64(counter)
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
 }
 
 func (c *PhotoFuniaClient) createRequestWithContext(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
@@ -349,16 +666,15 @@ func (c *PhotoFuniaClient) createRequestWithContext(ctx context.Context, method,
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 	req.Header.Set("Cache-Control", "max-age=0")
 	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Origin", baseURL)
-	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Origin", c.site())
+	req.Header.Set("User-Agent", c.agent())
 
-	if c.PHPSESSID == "" {
-		if err := c.generateSessIDWithContext(ctx); err != nil {
-			return nil, err
-		}
+	sessID, err := c.sessionIDWithContext(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	req.Header.Set("Cookie", fmt.Sprintf("accept_cookie=true; PHPSESSID=%s", c.PHPSESSID))
+	req.Header.Set("Cookie", fmt.Sprintf("accept_cookie=true; PHPSESSID=%s", sessID))
 
 	return req, nil
 }
@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 type MockLogger struct {
@@ -372,4 +373,49 @@ func TestErrorHandling(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestWithTimeoutKeepsTransport(t *testing.T) {
+	transport := &MockTransport{}
+	client := NewPhotoFuniaClient().WithTransport(transport).WithTimeout(5 * time.Second)
+
+	if client.client.Transport != transport {
+		t.Errorf("WithTimeout() dropped the custom transport")
+	}
+	if client.client.Timeout != 5*time.Second || client.timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want %v", client.client.Timeout, 5*time.Second)
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	original := NewPhotoFuniaClient()
+	httpClient := &http.Client{Timeout: time.Minute}
+
+	client := original.WithHTTPClient(httpClient)
+	if client.client != httpClient {
+		t.Error("WithHTTPClient() did not use the provided http.Client")
+	}
+	if client.timeout != time.Minute {
+		t.Errorf("timeout = %v, want %v", client.timeout, time.Minute)
+	}
+
+	client.WithTransport(&MockTransport{})
+	if httpClient.Transport != nil {
+		t.Error("WithTransport() modified the shared http.Client")
+	}
+	if original.client == httpClient {
+		t.Error("WithHTTPClient() modified the original client")
+	}
+}
+
+func TestWithHTTPClientNil(t *testing.T) {
+	transport := &MockTransport{}
+	client := NewClient(WithTransport(transport), WithTimeout(5*time.Second), WithHTTPClient(nil))
+
+	if client.client == nil || client.client.Transport != transport {
+		t.Error("WithHTTPClient(nil) replaced the current http.Client")
+	}
+	if client.timeout != 5*time.Second {
+		t.Errorf("timeout = %v, want %v", client.timeout, 5*time.Second)
+	}
+}
@@ -0,0 +1,317 @@
+package photofuniatest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+// RedactedSession replaces PHPSESSID values in recorded exchanges.
+const RedactedSession = "recorded-session"
+
+var sessionPattern = regexp.MustCompile(`PHPSESSID=([^;,\s]*)`)
+
+// Recording is a sequence of HTTP exchanges saved as a fixture file.
+type Recording struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded request and the response it received.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the recorded form of an http.Request.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body"`
+}
+
+// RecordedResponse is the recorded form of an http.Response.
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body"`
+}
+
+// Body is a recorded message body. It is saved as text when it is valid
+// UTF-8, such as HTML and JSON, and as base64 otherwise, such as images.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return marshalJSON(struct {
+			Text string `json:"text"`
+		}{string(b)}, "")
+	}
+	return marshalJSON(struct {
+		Base64 string `json:"base64"`
+	}{base64.StdEncoding.EncodeToString(b)}, "")
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var body struct {
+		Text   *string `json:"text"`
+		Base64 *string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+
+	switch {
+	case body.Base64 != nil:
+		decoded, err := base64.StdEncoding.DecodeString(*body.Base64)
+		if err != nil {
+			return fmt.Errorf("invalid base64 body: %w", err)
+		}
+		*b = decoded
+	case body.Text != nil:
+		*b = Body(*body.Text)
+	default:
+		*b = nil
+	}
+	return nil
+}
+
+// LoadRecording reads a recording from a fixture file written by Recorder.Save.
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var recording Recording
+	if err := json.Unmarshal(data, &recording); err != nil {
+		return nil, fmt.Errorf("failed to decode recording %s: %w", path, err)
+	}
+	return &recording, nil
+}
+
+// Save writes the recording to a fixture file.
+func (r *Recording) Save(path string) error {
+	data, err := marshalJSON(r, "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// marshalJSON encodes v without escaping HTML characters, which keeps
+// recorded HTML bodies readable in fixture files.
+func marshalJSON(v any, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Recorder is an http.RoundTripper that records every exchange made through
+// it, so that a real run can be saved and replayed later with a Replayer.
+// PHPSESSID values are replaced by RedactedSession in the recording,
+// including where they appear in URLs and bodies.
+// It is safe for concurrent use.
+type Recorder struct {
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	sessions     map[string]bool
+}
+
+// NewRecorder returns a Recorder sending requests through next,
+// or http.DefaultTransport if next is nil.
+func NewRecorder(next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next, sessions: make(map[string]bool)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = data
+
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+		req.ContentLength = int64(len(reqBody))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	r.collectSessions(req.Header)
+	r.collectSessions(resp.Header)
+	r.interactions = append(r.interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    string(r.redact([]byte(req.URL.String()))),
+			Header: redactHeader(req.Header),
+			Body:   r.redact(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+			Body:       r.redact(respBody),
+		},
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// collectSessions remembers the PHPSESSID values found in the cookie headers.
+func (r *Recorder) collectSessions(header http.Header) {
+	for _, name := range []string{"Cookie", "Set-Cookie"} {
+		for _, value := range header[name] {
+			for _, match := range sessionPattern.FindAllStringSubmatch(value, -1) {
+				if match[1] != "" {
+					r.sessions[match[1]] = true
+				}
+			}
+		}
+	}
+}
+
+// redact returns a copy of data with the known PHPSESSID values replaced.
+func (r *Recorder) redact(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+
+	redacted := bytes.Clone(data)
+	for session := range r.sessions {
+		redacted = bytes.ReplaceAll(redacted, []byte(session), []byte(RedactedSession))
+	}
+	return redacted
+}
+
+// Recording returns the exchanges recorded so far.
+func (r *Recorder) Recording() *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &Recording{Interactions: append([]Interaction(nil), r.interactions...)}
+}
+
+// Save writes the exchanges recorded so far to a fixture file.
+func (r *Recorder) Save(path string) error {
+	return r.Recording().Save(path)
+}
+
+// redactHeader returns a copy of header with PHPSESSID values redacted.
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range []string{"Cookie", "Set-Cookie"} {
+		for i, value := range redacted[name] {
+			redacted[name][i] = sessionPattern.ReplaceAllString(value, "PHPSESSID="+RedactedSession)
+		}
+	}
+	return redacted
+}
+
+// Replayer is an http.RoundTripper that serves the responses of a Recording.
+// A request is answered by the first unused interaction with the same method
+// and URL, so repeated requests are answered in the order they were recorded.
+// It is safe for concurrent use.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer returns a Replayer serving the given recording.
+func NewReplayer(recording *Recording) *Replayer {
+	return &Replayer{
+		interactions: recording.Interactions,
+		used:         make([]bool, len(recording.Interactions)),
+	}
+}
+
+// LoadReplayer returns a Replayer serving the recording in a fixture file.
+func LoadReplayer(path string) (*Replayer, error) {
+	recording, err := LoadRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(recording), nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || interaction.Request.URL != req.URL.String() {
+			continue
+		}
+		r.used[i] = true
+
+		recorded := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(recorded.Body)),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("photofuniatest: no recorded response for %s %s", req.Method, req.URL)
+}
+
+// Unused returns the number of recorded interactions that have not been replayed.
+func (r *Replayer) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	unused := 0
+	for _, used := range r.used {
+		if !used {
+			unused++
+		}
+	}
+	return unused
+}
@@ -0,0 +1,104 @@
+package photofuniatest_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swiftyspiffy/photofunia"
+	"github.com/swiftyspiffy/photofunia/photofuniatest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := photofuniatest.NewServer()
+	recorder := photofuniatest.NewRecorder(server.Client().Transport)
+	client := photofunia.NewClient(photofunia.WithBaseURL(server.URL), photofunia.WithTransport(recorder))
+
+	recorded, err := client.ApplyEffectResult(context.Background(), photofunia.FatMaker, testImage(), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffectResult() while recording error = %v", err)
+	}
+	server.Close()
+
+	path := filepath.Join(t.TempDir(), "fat_maker.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(fixture), "fake-session-") {
+		t.Error("fixture contains the PHPSESSID")
+	}
+	if !strings.Contains(string(fixture), "PHPSESSID="+photofuniatest.RedactedSession) {
+		t.Error("fixture does not contain the redacted PHPSESSID")
+	}
+
+	replayer, err := photofuniatest.LoadReplayer(path)
+	if err != nil {
+		t.Fatalf("LoadReplayer() error = %v", err)
+	}
+	client = photofunia.NewClient(photofunia.WithBaseURL(server.URL), photofunia.WithTransport(replayer))
+
+	replayed, err := client.ApplyEffectResult(context.Background(), photofunia.FatMaker, testImage(), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffectResult() while replaying error = %v", err)
+	}
+
+	if !bytes.Equal(replayed.Data, recorded.Data) {
+		t.Errorf("replayed data = %q, want %q", replayed.Data, recorded.Data)
+	}
+	if replayed.ResultURL != recorded.ResultURL || replayed.ImageKey != recorded.ImageKey {
+		t.Errorf("replayed result = %s %s, want %s %s", replayed.ResultURL, replayed.ImageKey, recorded.ResultURL, recorded.ImageKey)
+	}
+	if client.SessionID() != photofuniatest.RedactedSession {
+		t.Errorf("PHPSESSID = %q, want %q", client.SessionID(), photofuniatest.RedactedSession)
+	}
+	if n := replayer.Unused(); n != 0 {
+		t.Errorf("Unused() = %d, want 0", n)
+	}
+
+	_, err = client.ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil)
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("ApplyEffect() after replay error = %v, want no recorded response", err)
+	}
+}
+
+func TestBodyEncoding(t *testing.T) {
+	recording := &photofuniatest.Recording{Interactions: []photofuniatest.Interaction{{
+		Request:  photofuniatest.RecordedRequest{Method: "GET", URL: "https://photofunia.com/results/1", Body: photofuniatest.Body("<html></html>")},
+		Response: photofuniatest.RecordedResponse{StatusCode: 200, Body: photofuniatest.Body{0x89, 'P', 'N', 'G', 0xff}},
+	}}}
+
+	path := filepath.Join(t.TempDir(), "recording.json")
+	if err := recording.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(fixture), `"text": "<html></html>"`) {
+		t.Errorf("text body not saved as text:\n%s", fixture)
+	}
+	if !strings.Contains(string(fixture), `"base64": "iVBOR/8="`) {
+		t.Errorf("binary body not saved as base64:\n%s", fixture)
+	}
+
+	loaded, err := photofuniatest.LoadRecording(path)
+	if err != nil {
+		t.Fatalf("LoadRecording() error = %v", err)
+	}
+	if got := loaded.Interactions[0].Response.Body; !bytes.Equal(got, recording.Interactions[0].Response.Body) {
+		t.Errorf("loaded body = %v, want %v", got, recording.Interactions[0].Response.Body)
+	}
+	if got := string(loaded.Interactions[0].Request.Body); got != "<html></html>" {
+		t.Errorf("loaded body = %q, want %q", got, "<html></html>")
+	}
+}
@@ -0,0 +1,413 @@
+// Package photofuniatest provides an in-process fake of the PhotoFunia site
+// for testing code built on the photofunia package.
+//
+// The fake implements the endpoints the client uses to apply an effect:
+// /cookie-warning hands out a PHPSESSID, /images?server=1 accepts uploads,
+// POST /categories/<path> redirects to a result page, and the result page
+// links the result image through img#result-image.
+//
+//	server := photofuniatest.NewServer()
+//	defer server.Close()
+//
+//	client := server.NewClient()
+//	data, err := client.ApplyEffect(ctx, photofunia.FatMaker, img, nil)
+//
+//	submission := server.Submissions()[0]
+//	// submission.Form["size"] == "XXXXXL"
+package photofuniatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swiftyspiffy/photofunia"
+)
+
+// Endpoint identifies a group of requests handled by the Server.
+type Endpoint string
+
+const (
+	// EndpointSession is GET /cookie-warning, which sets the PHPSESSID cookie.
+	EndpointSession Endpoint = "session"
+
+	// EndpointUpload is POST /images?server=1, which stores an uploaded image.
+	EndpointUpload Endpoint = "upload"
+
+	// EndpointApply is POST /categories/<path>, which applies an effect and
+	// redirects to the result page.
+	EndpointApply Endpoint = "apply"
+
+	// EndpointResult is GET /results/<id>, the result page.
+	EndpointResult Endpoint = "result"
+
+	// EndpointImage is GET /downloads/<id>.png, the result image.
+	EndpointImage Endpoint = "image"
+)
+
+// ImageLifetime is how long the Server reports uploaded images to be kept.
+const ImageLifetime = time.Hour
+
+// Hook intercepts a request to an endpoint before the Server handles it.
+// It returns true if it wrote a response itself, in which case the Server
+// does not handle the request.
+type Hook func(w http.ResponseWriter, r *http.Request) bool
+
+// Fail returns a Hook that responds to every request with the given status.
+func Fail(status int) Hook {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		http.Error(w, http.StatusText(status), status)
+		return true
+	}
+}
+
+// FailTimes returns a Hook that responds to the first n requests with the
+// given status and lets the Server handle the following ones.
+func FailTimes(n, status int) Hook {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if n <= 0 {
+			return false
+		}
+		n--
+		http.Error(w, http.StatusText(status), status)
+		return true
+	}
+}
+
+// ErrorPage returns a Hook that answers with a page showing message in an
+// error block, as PhotoFunia does when it rejects a photo. Set it on
+// EndpointResult to make effects fail with a photofunia.PageError.
+func ErrorPage(message string) Hook {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, `<html><body><div class="alert alert-danger">%s</div></body></html>`, html.EscapeString(message))
+		return true
+	}
+}
+
+// Upload is an image received by the Server.
+type Upload struct {
+	// Key is the key the Server assigned to the image.
+	Key string
+
+	// SessionID is the PHPSESSID the image was uploaded with.
+	SessionID string
+
+	// Data is the uploaded image data.
+	Data []byte
+}
+
+// Submission is an effect form received by the Server.
+type Submission struct {
+	// Path is the category path of the effect, for example "faces/fat_maker".
+	Path string
+
+	// SessionID is the PHPSESSID the form was submitted with.
+	SessionID string
+
+	// Form holds the submitted form fields.
+	Form map[string]string
+
+	// ResultURL is the URL of the result page the Server redirected to.
+	ResultURL string
+}
+
+// Server is a fake PhotoFunia site running on a local httptest server.
+// It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	hooks       map[Endpoint]Hook
+	counts      map[Endpoint]int
+	sessions    int
+	expired     map[string]bool
+	uploads     []Upload
+	submissions []Submission
+	resultImage []byte
+	contentType string
+}
+
+// NewServer starts and returns a new Server.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		hooks:       make(map[Endpoint]Hook),
+		counts:      make(map[Endpoint]int),
+		expired:     make(map[string]bool),
+		resultImage: defaultResultImage(),
+		contentType: "image/png",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cookie-warning", s.handle(EndpointSession, s.serveSession))
+	mux.HandleFunc("/images", s.handle(EndpointUpload, s.serveUpload))
+	mux.HandleFunc("/categories/", s.handle(EndpointApply, s.serveApply))
+	mux.HandleFunc("/results/", s.handle(EndpointResult, s.serveResult))
+	mux.HandleFunc("/downloads/", s.handle(EndpointImage, s.serveImage))
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// NewClient returns a photofunia client that talks to the Server.
+// The given options are applied after the ones pointing the client
+// at the Server.
+func (s *Server) NewClient(opts ...photofunia.Option) *photofunia.PhotoFuniaClient {
+	return photofunia.NewClient(append([]photofunia.Option{
+		photofunia.WithBaseURL(s.URL),
+		photofunia.WithTransport(s.Client().Transport),
+	}, opts...)...)
+}
+
+// SetHook sets the hook called before requests to the endpoint are handled,
+// replacing any previous one. A nil hook removes it.
+func (s *Server) SetHook(endpoint Endpoint, hook Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if hook == nil {
+		delete(s.hooks, endpoint)
+		return
+	}
+	s.hooks[endpoint] = hook
+}
+
+// SetResultImage sets the image served as the result of every effect.
+// It defaults to a small PNG image.
+func (s *Server) SetResultImage(data []byte, contentType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resultImage = data
+	s.contentType = contentType
+}
+
+// ExpireSessions expires every session handed out so far. Like PhotoFunia,
+// the Server then redirects requests made with one of them back to
+// /cookie-warning. Sessions it did not hand out are always accepted.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 1; i <= s.sessions; i++ {
+		s.expired[fmt.Sprintf("fake-session-%d", i)] = true
+	}
+}
+
+// Requests returns the number of requests received by the endpoint,
+// including requests answered by a hook.
+func (s *Server) Requests(endpoint Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.counts[endpoint]
+}
+
+// Uploads returns the images received by the Server, in order.
+func (s *Server) Uploads() []Upload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Upload(nil), s.uploads...)
+}
+
+// Submissions returns the effect forms received by the Server, in order.
+func (s *Server) Submissions() []Submission {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Submission(nil), s.submissions...)
+}
+
+// handle counts the request, redirects it if its session has expired and
+// runs the endpoint hook before next.
+func (s *Server) handle(endpoint Endpoint, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.counts[endpoint]++
+		hook := s.hooks[endpoint]
+		expired := endpoint != EndpointSession && s.expired[sessionID(r)]
+		s.mu.Unlock()
+
+		if expired {
+			http.Redirect(w, r, "/cookie-warning", http.StatusFound)
+			return
+		}
+		if hook != nil && hook(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) serveSession(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.sessions++
+	sessID := fmt.Sprintf("fake-session-%d", s.sessions)
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: sessID, Path: "/"})
+	io.WriteString(w, "<html><body>Cookies accepted</body></html>")
+}
+
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	files, _, err := readMultipart(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, ok := files["image"]
+	if !ok {
+		http.Error(w, "missing image", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	key := fmt.Sprintf("fake-key-%d", len(s.uploads)+1)
+	s.uploads = append(s.uploads, Upload{Key: key, SessionID: sessionID(r), Data: data})
+	s.mu.Unlock()
+
+	now := time.Now()
+	imageURL := s.URL + "/uploads/" + key + ".png"
+	response := struct {
+		Response photofunia.UploadResult `json:"response"`
+	}{photofunia.UploadResult{
+		Key:      key,
+		Server:   1,
+		Expiry:   now.Add(ImageLifetime).Unix(),
+		Created:  now.Unix(),
+		Lifetime: int(ImageLifetime / time.Second),
+		Image: photofunia.UploadedImageVersions{
+			Highres: photofunia.ImageVersion{URL: imageURL},
+			Preview: photofunia.ImageVersion{URL: imageURL},
+			Thumb:   photofunia.ImageVersion{URL: imageURL},
+		},
+		Sid: sessionID(r),
+	}}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) serveApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, form, err := readMultipart(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	resultURL := s.URL + "/results/" + strconv.Itoa(len(s.submissions)+1)
+	s.submissions = append(s.submissions, Submission{
+		Path:      strings.TrimPrefix(r.URL.Path, "/categories/"),
+		SessionID: sessionID(r),
+		Form:      form,
+		ResultURL: resultURL,
+	})
+	s.mu.Unlock()
+
+	http.Redirect(w, r, resultURL, http.StatusFound)
+}
+
+func (s *Server) serveResult(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/results/")
+	imageURL := s.URL + "/downloads/" + id + ".png"
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<html><body><div class="result"><img id="result-image" src="%s" alt="Result"></div></body></html>`, html.EscapeString(imageURL))
+}
+
+func (s *Server) serveImage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, contentType := s.resultImage, s.contentType
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// readMultipart reads a multipart request body, returning its file parts
+// and its other fields separately.
+func readMultipart(r *http.Request) (map[string][]byte, map[string]string, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid content type: %w", err)
+	}
+
+	files := make(map[string][]byte)
+	form := make(map[string]string)
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return files, form, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+
+		if part.FileName() != "" {
+			files[part.FormName()] = value
+		} else {
+			form[part.FormName()] = string(value)
+		}
+	}
+}
+
+// sessionID returns the PHPSESSID cookie of the request.
+func sessionID(r *http.Request) string {
+	cookie, err := r.Cookie("PHPSESSID")
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// defaultResultImage returns a small PNG image.
+func defaultResultImage() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 3; y++ {
+			img.Set(x, y, color.RGBA{R: 255, G: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
@@ -0,0 +1,214 @@
+package photofuniatest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/swiftyspiffy/photofunia"
+	"github.com/swiftyspiffy/photofunia/photofuniatest"
+)
+
+func testImage() io.ReadCloser {
+	return io.NopCloser(bytes.NewReader([]byte("fake-image-data")))
+}
+
+func TestServerApplyEffect(t *testing.T) {
+	server := photofuniatest.NewServer()
+	defer server.Close()
+
+	client := server.NewClient()
+
+	result, err := client.ApplyEffectResult(context.Background(), photofunia.FatMaker, testImage(), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffectResult() error = %v", err)
+	}
+
+	if result.MIMEType != "image/png" || result.Width != 4 || result.Height != 3 {
+		t.Errorf("result = %s %dx%d, want image/png 4x3", result.MIMEType, result.Width, result.Height)
+	}
+
+	uploads := server.Uploads()
+	if len(uploads) != 1 || string(uploads[0].Data) != "fake-image-data" {
+		t.Fatalf("Uploads() = %+v, want one upload of the image", uploads)
+	}
+
+	submissions := server.Submissions()
+	if len(submissions) != 1 {
+		t.Fatalf("Submissions() returned %d submissions, want 1", len(submissions))
+	}
+
+	submission := submissions[0]
+	if submission.Path != "faces/fat_maker" {
+		t.Errorf("Path = %q, want %q", submission.Path, "faces/fat_maker")
+	}
+	if submission.Form["image"] != uploads[0].Key {
+		t.Errorf("image field = %q, want %q", submission.Form["image"], uploads[0].Key)
+	}
+	if submission.Form["size"] != "XXXXXL" {
+		t.Errorf("size field = %q, want %q", submission.Form["size"], "XXXXXL")
+	}
+	if submission.SessionID == "" || submission.SessionID != uploads[0].SessionID {
+		t.Errorf("SessionID = %q, want the upload session %q", submission.SessionID, uploads[0].SessionID)
+	}
+	if result.ResultURL != submission.ResultURL {
+		t.Errorf("ResultURL = %q, want %q", result.ResultURL, submission.ResultURL)
+	}
+
+	if n := server.Requests(photofuniatest.EndpointSession); n != 1 {
+		t.Errorf("Requests(EndpointSession) = %d, want 1", n)
+	}
+}
+
+func TestServerSetResultImage(t *testing.T) {
+	server := photofuniatest.NewServer()
+	defer server.Close()
+
+	server.SetResultImage([]byte("custom-result"), "image/jpeg")
+
+	data, err := server.NewClient().ApplyEffect(context.Background(), photofunia.Clown, testImage(), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+	if string(data) != "custom-result" {
+		t.Errorf("data = %q, want %q", data, "custom-result")
+	}
+}
+
+func TestServerHooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint photofuniatest.Endpoint
+		wantErr  string
+	}{
+		{name: "session", endpoint: photofuniatest.EndpointSession, wantErr: "PHPSESSID"},
+		{name: "upload", endpoint: photofuniatest.EndpointUpload, wantErr: "server returned non-OK status"},
+		{name: "apply", endpoint: photofuniatest.EndpointApply, wantErr: "server returned non-OK status"},
+		{name: "result", endpoint: photofuniatest.EndpointResult, wantErr: "server returned non-OK status"},
+		{name: "image", endpoint: photofuniatest.EndpointImage, wantErr: "server returned non-OK status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := photofuniatest.NewServer()
+			defer server.Close()
+
+			server.SetHook(tt.endpoint, photofuniatest.Fail(http.StatusInternalServerError))
+
+			_, err := server.NewClient().ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ApplyEffect() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			if n := server.Requests(tt.endpoint); n != 1 {
+				t.Errorf("Requests(%s) = %d, want 1", tt.endpoint, n)
+			}
+		})
+	}
+}
+
+func TestServerFailTimes(t *testing.T) {
+	server := photofuniatest.NewServer()
+	defer server.Close()
+
+	server.SetHook(photofuniatest.EndpointApply, photofuniatest.FailTimes(1, http.StatusServiceUnavailable))
+	client := server.NewClient()
+
+	if _, err := client.ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil); err == nil {
+		t.Fatal("first ApplyEffect() succeeded, want error")
+	}
+	if _, err := client.ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil); err != nil {
+		t.Fatalf("second ApplyEffect() error = %v", err)
+	}
+
+	if got := len(server.Submissions()); got != 1 {
+		t.Errorf("Submissions() returned %d submissions, want 1", got)
+	}
+
+	server.SetHook(photofuniatest.EndpointApply, nil)
+	if _, err := client.ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil); err != nil {
+		t.Fatalf("ApplyEffect() after removing hook error = %v", err)
+	}
+}
+
+func TestServerErrorPage(t *testing.T) {
+	server := photofuniatest.NewServer()
+	defer server.Close()
+
+	server.SetHook(photofuniatest.EndpointResult, photofuniatest.ErrorPage("No faces were found in your photo"))
+
+	_, err := server.NewClient().ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil)
+	if !errors.Is(err, photofunia.ErrNoFaceDetected) {
+		t.Errorf("ApplyEffect() error = %v, want ErrNoFaceDetected", err)
+	}
+}
+
+// seekableImage is an image the client can rewind to upload it again.
+type seekableImage struct {
+	*bytes.Reader
+}
+
+func (seekableImage) Close() error { return nil }
+
+func TestServerExpireSessions(t *testing.T) {
+	server := photofuniatest.NewServer()
+	defer server.Close()
+
+	client := server.NewClient()
+	if _, err := client.ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil); err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+
+	server.ExpireSessions()
+
+	img := seekableImage{bytes.NewReader([]byte("fake-image-data"))}
+	if _, err := client.ApplyEffect(context.Background(), photofunia.FatMaker, img, nil); err != nil {
+		t.Fatalf("ApplyEffect() after the session expired error = %v", err)
+	}
+
+	uploads := server.Uploads()
+	if len(uploads) != 2 || uploads[0].SessionID == uploads[1].SessionID {
+		t.Errorf("Uploads() = %+v, want the second upload made with a new session", uploads)
+	}
+	submissions := server.Submissions()
+	if len(submissions) != 2 || submissions[1].SessionID != uploads[1].SessionID {
+		t.Errorf("Submissions() = %+v, want the second one made with the new session", submissions)
+	}
+
+	server.ExpireSessions()
+
+	_, err := client.ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil)
+	if !errors.Is(err, photofunia.ErrSessionExpired) {
+		t.Errorf("ApplyEffect() with an image that cannot be uploaded again error = %v, want ErrSessionExpired", err)
+	}
+}
+
+func TestServerExpireSessionsNonSeekableUpload(t *testing.T) {
+	server := photofuniatest.NewServer()
+	defer server.Close()
+
+	client := server.NewClient()
+	if _, err := client.ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil); err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+	expired := client.SessionID()
+
+	server.ExpireSessions()
+
+	if _, err := client.Upload(context.Background(), testImage()); !errors.Is(err, photofunia.ErrSessionExpired) {
+		t.Fatalf("Upload() error = %v, want ErrSessionExpired", err)
+	}
+	if got := client.SessionID(); got == expired || got == "" {
+		t.Errorf("SessionID() = %q after the upload failed, want a new session", got)
+	}
+
+	if _, err := client.Upload(context.Background(), testImage()); err != nil {
+		t.Errorf("Upload() after the session was refreshed error = %v", err)
+	}
+	if _, err := client.ApplyEffect(context.Background(), photofunia.FatMaker, testImage(), nil); err != nil {
+		t.Errorf("ApplyEffect() after the session was refreshed error = %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"image"
+	_ "image/gif"  // register the GIF decoder for Result dimensions
+	_ "image/jpeg" // register the JPEG decoder for Result dimensions
+	_ "image/png"  // register the PNG decoder for Result dimensions
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timings holds the time spent in each stage of applying an effect.
+// Stages that were skipped, such as the upload of an image-less effect, are zero.
+type Timings struct {
+	// Session is the time spent obtaining a PHPSESSID.
+	Session time.Duration
+
+	// Upload is the time spent uploading the input images.
+	Upload time.Duration
+
+	// Apply is the time spent posting the effect form.
+	Apply time.Duration
+
+	// ResultPage is the time spent reading the result page.
+	ResultPage time.Duration
+
+	// Download is the time spent downloading the result image.
+	Download time.Duration
+}
+
+// Total returns the time spent in all stages.
+func (t Timings) Total() time.Duration {
+	return t.Session + t.Upload + t.Apply + t.ResultPage + t.Download
+}
+
+// Result is the outcome of applying an effect, carrying the result image
+// along with metadata about how it was produced.
+type Result struct {
+	// Data is the result image data.
+	Data []byte
+
+	// Size is the size of the result image in bytes.
+	Size int64
+
+	// ResultURL is the URL of the PhotoFunia result page.
+	ResultURL string
+
+	// ImageURL is the URL the result image was downloaded from.
+	ImageURL string
+
+	// MIMEType is the media type of the result image, such as "image/jpeg".
+	// It is detected from the data, falling back to the Content-Type sent by the server.
+	MIMEType string
+
+	// Width is the width of the result image in pixels, or 0 if it could not be decoded.
+	Width int
+
+	// Height is the height of the result image in pixels, or 0 if it could not be decoded.
+	Height int
+
+	// ImageKey is the key of the image sent as the effect's first image input.
+	// It is empty for effects that do not take an image.
+	ImageKey string
+
+	// ImageKeys maps every image input name to the key of the image sent for it.
+	ImageKeys map[string]string
+
+	// Timings holds the time spent in each stage.
+	Timings Timings
+}
+
+// setData sets the image data of the result and derives its MIME type
+// and dimensions.
+func (r *Result) setData(data []byte, contentType string) {
+	r.Data = data
+	r.Size = int64(len(data))
+	r.MIMEType = detectMIMEType(data, contentType)
+
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		r.Width = config.Width
+		r.Height = config.Height
+	}
+}
+
+// detectMIMEType sniffs the media type of data, preferring the server's
+// Content-Type when sniffing does not recognize an image.
+func detectMIMEType(data []byte, contentType string) string {
+	detected := http.DetectContentType(data)
+	if strings.HasPrefix(detected, "image/") {
+		return detected
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+
+	mediaType, _, _ := strings.Cut(detected, ";")
+	return mediaType
+}
+
+// FatifyResult applies the "fat maker" effect to the provided image with context support.
+// It returns the processed image along with metadata about the run.
+//
+// The img parameter should be an io.ReadCloser containing the image data.
+// The function will close the reader when done.
+func (c *PhotoFuniaClient) FatifyResult(ctx context.Context, img io.ReadCloser) (*Result, error) {
+	return c.ApplyEffectResult(ctx, FatMaker, img, nil)
+}
+
+// clownify is the clown effect as applied by Clownify, which posts it to the
+// "all_effects" category rather than to "faces" like Clown.
+var clownify = Effect{
+	Name: "clownify",
+	Path: "all_effects/clown",
+	Params: map[string]string{
+		"hat": "off",
+	},
+	Inputs: []Input{{Name: "image", Kind: InputImage}},
+}
+
+// ClownifyResult applies the clown effect to the provided image with context support.
+// It returns the processed image along with metadata about the run.
+//
+// The img parameter should be an io.ReadCloser containing the image data.
+// The function will close the reader when done.
+//
+// The includeHat parameter determines whether a clown hat is added to the image.
+func (c *PhotoFuniaClient) ClownifyResult(ctx context.Context, img io.ReadCloser, includeHat bool) (*Result, error) {
+	hat := "off"
+	if includeHat {
+		hat = "on"
+	}
+
+	return c.ApplyEffectResult(ctx, clownify, img, map[string]string{"hat": hat})
+}
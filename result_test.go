@@ -0,0 +1,106 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestApplyEffectResult(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "example.com/result.jpg") {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/octet-stream"}},
+				Body:       io.NopCloser(bytes.NewReader(encoded.Bytes())),
+			}, nil
+		}
+		return roundTrip(req)
+	}
+
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: transport},
+	}
+
+	result, err := client.FatifyResult(context.Background(), io.NopCloser(strings.NewReader("fake-image-data")))
+	if err != nil {
+		t.Fatalf("FatifyResult() error = %v", err)
+	}
+
+	if !bytes.Equal(result.Data, encoded.Bytes()) {
+		t.Error("FatifyResult() returned unexpected data")
+	}
+	if result.ResultURL != "https://photofunia.com/results/result123" {
+		t.Errorf("ResultURL = %q", result.ResultURL)
+	}
+	if result.ImageURL != "https://example.com/result.jpg" {
+		t.Errorf("ImageURL = %q", result.ImageURL)
+	}
+	if result.MIMEType != "image/png" {
+		t.Errorf("MIMEType = %q, want image/png", result.MIMEType)
+	}
+	if result.Width != 4 || result.Height != 3 {
+		t.Errorf("dimensions = %dx%d, want 4x3", result.Width, result.Height)
+	}
+	if result.ImageKey != "test-image-key" || result.ImageKeys["image"] != "test-image-key" {
+		t.Errorf("ImageKey = %q, ImageKeys = %v", result.ImageKey, result.ImageKeys)
+	}
+	if result.Timings.Total() <= 0 {
+		t.Errorf("Timings.Total() = %v, want positive", result.Timings.Total())
+	}
+}
+
+func TestClownifyResultUndecodableImage(t *testing.T) {
+	transport := newEffectTransport(t, "all_effects/clown", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		resp, err := roundTrip(req)
+		if err == nil && strings.Contains(req.URL.String(), "example.com/result.jpg") {
+			resp.Header = http.Header{"Content-Type": []string{"image/jpeg; charset=binary"}}
+		}
+		return resp, err
+	}
+
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: transport},
+	}
+
+	result, err := client.ClownifyResult(context.Background(), io.NopCloser(strings.NewReader("fake-image-data")), true)
+	if err != nil {
+		t.Fatalf("ClownifyResult() error = %v", err)
+	}
+
+	if result.MIMEType != "image/jpeg" {
+		t.Errorf("MIMEType = %q, want the server Content-Type image/jpeg", result.MIMEType)
+	}
+	if result.Width != 0 || result.Height != 0 {
+		t.Errorf("dimensions = %dx%d, want 0x0", result.Width, result.Height)
+	}
+}
+
+func TestClownifyResultForm(t *testing.T) {
+	var form map[string]string
+	transport := newEffectTransport(t, "all_effects/clown", func(f map[string]string) { form = f })
+	client := NewClient(WithTransport(transport))
+
+	if _, err := client.ClownifyResult(context.Background(), io.NopCloser(strings.NewReader("fake-image-data")), true); err != nil {
+		t.Fatalf("ClownifyResult() error = %v", err)
+	}
+	if form["current-category"] != "all_effects" || form["hat"] != "on" || form["image"] != "test-image-key" {
+		t.Errorf("form = %v, want current-category all_effects, hat on and the image key", form)
+	}
+}
@@ -0,0 +1,302 @@
+package photofunia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryableStatusCodes are the HTTP statuses retried by DefaultRetryPolicy.
+var DefaultRetryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy controls how failed stages of applying an effect are retried.
+// Each stage is retried on its own, so a failed apply request does not
+// repeat a successful upload. The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a stage is attempted, including the
+	// first attempt. Values below 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. Zero means no cap.
+	// A longer Retry-After requested by the server is still honored.
+	MaxBackoff time.Duration
+
+	// Multiplier scales the delay after each attempt. It defaults to 2.
+	Multiplier float64
+
+	// Jitter is the fraction, between 0 and 1, of each delay that is randomized,
+	// so that clients failing together do not retry together.
+	Jitter float64
+
+	// RetryableStatusCodes lists the HTTP statuses that are retried.
+	RetryableStatusCodes []int
+
+	// RetryNetworkErrors retries requests that failed without a response,
+	// such as refused or reset connections and timeouts.
+	RetryNetworkErrors bool
+
+	// Stages lists the stages that are retried. Nil means every stage.
+	// Uploads are only retried when the image reader implements io.Seeker,
+	// so that the image can be sent again.
+	Stages []Stage
+}
+
+// DefaultRetryPolicy returns a policy making up to 3 attempts per stage,
+// with exponential backoff from 500ms to 10s, retrying network errors and
+// DefaultRetryableStatusCodes.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:          3,
+		InitialBackoff:       500 * time.Millisecond,
+		MaxBackoff:           10 * time.Second,
+		Multiplier:           2,
+		Jitter:               0.2,
+		RetryableStatusCodes: DefaultRetryableStatusCodes,
+		RetryNetworkErrors:   true,
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed stages.
+// Returns a new client using the provided policy.
+func (c *PhotoFuniaClient) WithRetryPolicy(policy RetryPolicy) *PhotoFuniaClient {
+	return c.With(WithRetryPolicy(policy))
+}
+
+// appliesTo reports whether the policy retries the stage.
+func (p RetryPolicy) appliesTo(stage Stage) bool {
+	if p.MaxAttempts < 2 {
+		return false
+	}
+	if p.Stages == nil {
+		return true
+	}
+	for _, s := range p.Stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable reports whether err may succeed when the stage is attempted again.
+func (p RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var stageErr *Error
+	if !errors.As(err, &stageErr) {
+		return false
+	}
+
+	if stageErr.StatusCode != 0 {
+		for _, code := range p.RetryableStatusCodes {
+			if stageErr.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	return p.RetryNetworkErrors && isNetworkError(stageErr.Err)
+}
+
+// backoff returns the delay before the attempt following the given one.
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	delay := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * min(p.Jitter, 1) * rand.Float64()
+	}
+
+	var stageErr *Error
+	if errors.As(err, &stageErr) && stageErr.RetryAfter > time.Duration(delay) {
+		return stageErr.RetryAfter
+	}
+	return time.Duration(delay)
+}
+
+// isNetworkError reports whether err is a transient failure to get a
+// response, such as a reset or refused connection or a timeout, as opposed to
+// an error response or a failure that would happen again, such as an
+// untrusted certificate or an invalid URL.
+func isNetworkError(err error) bool {
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// transientErrors are the errors of a request that may succeed when made again.
+var transientErrors = []error{
+	io.EOF,
+	io.ErrUnexpectedEOF,
+	syscall.ECONNRESET,
+	syscall.ECONNREFUSED,
+	syscall.ECONNABORTED,
+	syscall.EPIPE,
+	syscall.ETIMEDOUT,
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// withRetry runs attempt until it succeeds or the retry policy of the client
+// gives up on the stage. It returns the error of the last attempt.
+//
+// When an attempt fails with ErrSessionExpired, the session is replaced and
+// the attempt repeated once, whatever the retry policy. The new session is
+// recorded in cp, if the stage belongs to a run. Result pages are left to
+// prepareResultWithContext, as reading one again with a new session cannot
+// succeed.
+func (c *PhotoFuniaClient) withRetry(ctx context.Context, stage Stage, cp *Checkpoint, attempt func() error) error {
+	policy := c.retryPolicy
+	refreshed := false
+
+	for n := 1; ; n++ {
+		session := c.sessionState().current()
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		if !refreshed && stage != StageSession && stage != StageResultPage && errors.Is(err, ErrSessionExpired) {
+			refreshed = true
+			c.logger.Info("session expired, generating new PHPSESSID", Field{"stage", string(stage)})
+			id, err := c.refreshSession(ctx, session)
+			if err != nil {
+				return err
+			}
+			if cp != nil {
+				cp.SessionID = id
+			}
+			n--
+			continue
+		}
+		if n >= policy.MaxAttempts || !policy.appliesTo(stage) || !policy.retryable(err) {
+			return err
+		}
+
+		delay := policy.backoff(n, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		c.logger.Info("retrying failed stage",
+			Field{"stage", string(stage)},
+			Field{"attempt", n + 1},
+			Field{"delay", delay},
+			Field{"error", err.Error()})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w while waiting to retry: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// uploadImageWithRetry uploads an image, retrying according to the retry
+// policy of the client or after refreshing an expired session, when the
+// image reader can be rewound.
+// It closes imageReader when done.
+func (c *PhotoFuniaClient) uploadImageWithRetry(ctx context.Context, imageReader io.ReadCloser) (*UploadResult, error) {
+	// Obtain the session first, so that an expired one is known to refresh it.
+	session, err := c.sessionIDWithContext(ctx)
+	if err != nil {
+		imageReader.Close()
+		return nil, err
+	}
+
+	seeker, ok := imageReader.(io.Seeker)
+	if !ok {
+		result, err := c.uploadImageWithContext(ctx, imageReader)
+		if errors.Is(err, ErrSessionExpired) {
+			// The image cannot be sent again, but the next request must not
+			// reuse the expired session.
+			if _, refreshErr := c.refreshSession(ctx, session); refreshErr != nil {
+				c.logger.Info("failed to refresh expired session", Field{"error", refreshErr.Error()})
+			}
+		}
+		return result, err
+	}
+	defer imageReader.Close()
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return c.uploadImageWithContext(ctx, rewoundImage{imageReader})
+	}
+
+	var result *UploadResult
+	err = c.withRetry(ctx, StageUpload, nil, func() error {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind image: %w", err)
+		}
+
+		var err error
+		result, err = c.uploadImageWithContext(ctx, rewoundImage{imageReader})
+		return err
+	})
+	return result, err
+}
+
+// rewoundImage hides the Close method of an image reader between upload
+// attempts, while keeping its size known to imageSize.
+type rewoundImage struct {
+	io.ReadCloser
+}
+
+func (rewoundImage) Close() error { return nil }
@@ -0,0 +1,356 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// seekableImage is an image reader that can be rewound for upload retries.
+type seekableImage struct {
+	*bytes.Reader
+	closed bool
+}
+
+func (s *seekableImage) Close() error {
+	s.closed = true
+	return nil
+}
+
+// failingTransport wraps newEffectTransport, answering the first failures
+// requests matching method and match with the given response.
+func failingTransport(t *testing.T, method, match string, failures int, failure func() (*http.Response, error)) (*MockTransport, func() int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	attempts := 0
+
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if req.Method == method && strings.Contains(req.URL.String(), match) {
+			mu.Lock()
+			attempts++
+			failing := attempts <= failures
+			mu.Unlock()
+
+			if failing {
+				if req.Body != nil {
+					io.Copy(io.Discard, req.Body)
+				}
+				return failure()
+			}
+		}
+		return roundTrip(req)
+	}
+
+	return transport, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts
+	}
+}
+
+func statusResponse(status int, header http.Header) func() (*http.Response, error) {
+	return func() (*http.Response, error) {
+		return &http.Response{
+			Status:     http.StatusText(status),
+			StatusCode: status,
+			Header:     header,
+			Body:       http.NoBody,
+		}, nil
+	}
+}
+
+func connectionReset() (*http.Response, error) {
+	return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+}
+
+func testRetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+func TestRetryApplyKeepsUpload(t *testing.T) {
+	transport, applyAttempts := failingTransport(t, "POST", "/categories/faces/fat_maker", 1, statusResponse(http.StatusBadGateway, nil))
+
+	var mu sync.Mutex
+	uploads := 0
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "/images") {
+			mu.Lock()
+			uploads++
+			mu.Unlock()
+		}
+		return roundTrip(req)
+	}
+
+	client := NewClient(WithTransport(transport), WithRetryPolicy(testRetryPolicy()))
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+	if got := applyAttempts(); got != 2 {
+		t.Errorf("apply attempts = %d, want 2", got)
+	}
+	if uploads != 1 {
+		t.Errorf("uploads = %d, want 1", uploads)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		failure      func() (*http.Response, error)
+		wantAttempts int
+	}{
+		{
+			name:         "No policy",
+			failure:      statusResponse(http.StatusBadGateway, nil),
+			wantAttempts: 1,
+		},
+		{
+			name:         "Non-retryable status",
+			policy:       testRetryPolicy(),
+			failure:      statusResponse(http.StatusBadRequest, nil),
+			wantAttempts: 1,
+		},
+		{
+			name:         "Max attempts",
+			policy:       testRetryPolicy(),
+			failure:      statusResponse(http.StatusServiceUnavailable, nil),
+			wantAttempts: 3,
+		},
+		{
+			name: "Stage not retried",
+			policy: func() RetryPolicy {
+				policy := testRetryPolicy()
+				policy.Stages = []Stage{StageDownload}
+				return policy
+			}(),
+			failure:      statusResponse(http.StatusServiceUnavailable, nil),
+			wantAttempts: 1,
+		},
+		{
+			name: "Network errors not retried",
+			policy: func() RetryPolicy {
+				policy := testRetryPolicy()
+				policy.RetryNetworkErrors = false
+				return policy
+			}(),
+			failure:      connectionReset,
+			wantAttempts: 1,
+		},
+		{
+			name:         "Network errors retried",
+			policy:       testRetryPolicy(),
+			failure:      connectionReset,
+			wantAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, attempts := failingTransport(t, "POST", "/categories/faces/fat_maker", 10, tt.failure)
+			client := NewClient(WithTransport(transport), WithRetryPolicy(tt.policy))
+
+			_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+			if err == nil {
+				t.Fatal("ApplyEffect() succeeded, want error")
+			}
+			if got := attempts(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryGivesUpOnUntrustedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	attempts := 0
+	transport := &MockTransport{RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return http.DefaultTransport.RoundTrip(req)
+	}}
+	client := NewClient(WithBaseURL(server.URL), WithTransport(transport), WithRetryPolicy(testRetryPolicy()))
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if !errors.Is(err, ErrSessionUnavailable) {
+		t.Fatalf("ApplyEffect() error = %v, want ErrSessionUnavailable", err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 as the certificate is not trusted", attempts)
+	}
+}
+
+func TestIsNetworkError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Connection reset", err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, want: true},
+		{name: "Connection refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: true},
+		{name: "Unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "Timeout", err: os.ErrDeadlineExceeded, want: true},
+		{name: "Temporary DNS failure", err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}, want: true},
+		{name: "Unknown host", err: &net.DNSError{Err: "no such host", IsNotFound: true}, want: false},
+		{name: "Other request error", err: errors.New("unsupported protocol scheme"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := error(&url.Error{Op: "Get", URL: "https://photofunia.com", Err: tt.err})
+			if got := isNetworkError(err); got != tt.want {
+				t.Errorf("isNetworkError(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryUploadNeedsSeeker(t *testing.T) {
+	transport, attempts := failingTransport(t, "POST", "/images", 1, statusResponse(http.StatusServiceUnavailable, nil))
+	client := NewClient(WithTransport(transport), WithRetryPolicy(testRetryPolicy()))
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if err == nil {
+		t.Fatal("ApplyEffect() with a non-seekable image succeeded, want error")
+	}
+	if got := attempts(); got != 1 {
+		t.Errorf("upload attempts with a non-seekable image = %d, want 1", got)
+	}
+
+	var uploaded []byte
+	transport, attempts = failingTransport(t, "POST", "/images", 1, statusResponse(http.StatusServiceUnavailable, nil))
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "/images") && attempts() >= 1 {
+			uploaded, _ = io.ReadAll(req.Body)
+			req.Body = io.NopCloser(bytes.NewReader(uploaded))
+		}
+		return roundTrip(req)
+	}
+	client = NewClient(WithTransport(transport), WithRetryPolicy(testRetryPolicy()))
+
+	img := &seekableImage{Reader: bytes.NewReader([]byte("fake-image-data"))}
+	if _, err := client.ApplyEffect(context.Background(), FatMaker, img, nil); err != nil {
+		t.Fatalf("ApplyEffect() with a seekable image error = %v", err)
+	}
+	if got := attempts(); got != 2 {
+		t.Errorf("upload attempts with a seekable image = %d, want 2", got)
+	}
+	if !bytes.Contains(uploaded, []byte("fake-image-data")) {
+		t.Errorf("retried upload body = %q, want the whole image", uploaded)
+	}
+	if !img.closed {
+		t.Error("image was not closed")
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	policy := testRetryPolicy()
+	err := &Error{Stage: StageApply, StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}
+
+	if delay := policy.backoff(1, err); delay != time.Minute {
+		t.Errorf("backoff() = %v, want Retry-After of %v", delay, time.Minute)
+	}
+
+	transport, attempts := failingTransport(t, "POST", "/categories/faces/fat_maker", 10,
+		statusResponse(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"60"}}))
+	client := NewClient(WithTransport(transport), WithRetryPolicy(policy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, applyErr := client.ApplyEffect(ctx, FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+
+	var stageErr *Error
+	if !errors.As(applyErr, &stageErr) || stageErr.RetryAfter != time.Minute {
+		t.Fatalf("ApplyEffect() error = %v, want *Error with RetryAfter", applyErr)
+	}
+	if got := attempts(); got != 1 {
+		t.Errorf("attempts = %d, want 1 as the Retry-After exceeds the deadline", got)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("ApplyEffect() took %v, want it to give up without waiting", elapsed)
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	policy := testRetryPolicy()
+	policy.InitialBackoff = time.Minute
+	policy.MaxBackoff = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	transport, _ := failingTransport(t, "POST", "/categories/faces/fat_maker", 10, func() (*http.Response, error) {
+		cancel()
+		return statusResponse(http.StatusBadGateway, nil)()
+	})
+	client := NewClient(WithTransport(transport), WithRetryPolicy(policy))
+
+	_, err := client.ApplyEffect(ctx, FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ApplyEffect() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := policy.backoff(i+1, errors.New("failure")); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(1, nil); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("backoff() with jitter = %v, want between 50ms and 100ms", got)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "120", want: 2 * time.Minute},
+		{value: "-1", want: 0},
+		{value: "soon", want: 0},
+		{value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(%q) = %v, want about an hour", future, got)
+	}
+}
@@ -0,0 +1,367 @@
+package photofunia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldKind identifies the type of a form field on an effect page.
+type FieldKind string
+
+const (
+	// FieldText is a free-form text input or textarea.
+	FieldText FieldKind = "text"
+
+	// FieldCheckbox is a toggle, such as the clown "hat". It is sent as the
+	// value of the checkbox, "on" unless the page sets one, or as "off".
+	FieldCheckbox FieldKind = "checkbox"
+
+	// FieldSelect is a choice between a fixed set of values, such as the fat maker "size".
+	// Radio button groups are reported as selects.
+	FieldSelect FieldKind = "select"
+
+	// FieldImage is an image input whose value is the key of an uploaded image.
+	FieldImage FieldKind = "image"
+
+	// FieldHidden is a hidden input whose value is normally left at its default.
+	FieldHidden FieldKind = "hidden"
+)
+
+// FormField describes a single field of an effect form.
+type FormField struct {
+	// Kind is the type of the field.
+	Kind FieldKind `json:"kind"`
+
+	// Name is the form field name the value is sent as.
+	Name string `json:"name"`
+
+	// Values lists the allowed values of checkbox and select fields.
+	Values []string `json:"values,omitempty"`
+
+	// Default is the value the effect page submits when the field is left untouched.
+	Default string `json:"default,omitempty"`
+
+	// Required reports whether the field must have a non-empty value.
+	Required bool `json:"required,omitempty"`
+
+	// MaxLength is the maximum number of characters of a text field, or 0 if unlimited.
+	MaxLength int `json:"maxLength,omitempty"`
+
+	// Charset restricts the characters of a text field.
+	Charset Charset `json:"charset,omitempty"`
+}
+
+// Schema describes the form fields an effect page submits.
+type Schema struct {
+	// Path is the category path the form submits to, for example "faces/fat_maker".
+	Path string `json:"path"`
+
+	// Fields lists the fields of the form in document order.
+	Fields []FormField `json:"fields"`
+}
+
+// ValidationError reports a parameter that does not match an effect's schema.
+type ValidationError struct {
+	// Field is the name of the offending form field.
+	Field string
+
+	// Reason describes why the value was rejected.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid value for field %s: %s", e.Field, e.Reason)
+}
+
+var (
+	formFieldPattern = regexp.MustCompile(`(?is)<select\s([^>]*)>(.*?)</select>|<textarea\s([^>]*)>(.*?)</textarea>|<input\s([^>]*)>`)
+	optionPattern    = regexp.MustCompile(`(?is)<option([^>]*)>(.*?)</option>`)
+	imageNamePattern = regexp.MustCompile(`^image\d*$`)
+)
+
+// ParseSchema parses the effect form of an effect page into a Schema.
+// It returns an error if the page does not contain an effect form.
+func ParseSchema(htmlContent []byte) (*Schema, error) {
+	match := effectFormMatch(htmlContent)
+	if match == nil {
+		return nil, errors.New("could not find effect form in HTML")
+	}
+
+	schema := &Schema{Path: strings.Join(categoryPathSegments(htmlAttr(string(match[1]), "action")), "/")}
+	index := make(map[string]int)
+
+	for _, field := range formFieldPattern.FindAllSubmatch(match[2], -1) {
+		var def FormField
+
+		switch {
+		case field[1] != nil:
+			def = parseSelectField(string(field[1]), string(field[2]))
+		case field[3] != nil:
+			def = parseTextField(string(field[3]), htmlText(string(field[4])))
+		default:
+			def = parseInputField(string(field[5]))
+		}
+
+		if def.Name == "" || def.Kind == "" {
+			continue
+		}
+
+		// Radio buttons share a name, so each one adds a value to the same field.
+		if i, ok := index[def.Name]; ok {
+			if def.Kind == FieldSelect && schema.Fields[i].Kind == FieldSelect {
+				schema.Fields[i].Values = append(schema.Fields[i].Values, def.Values...)
+				if def.Default != "" {
+					schema.Fields[i].Default = def.Default
+				}
+			}
+			continue
+		}
+
+		index[def.Name] = len(schema.Fields)
+		schema.Fields = append(schema.Fields, def)
+	}
+
+	return schema, nil
+}
+
+func parseSelectField(attrs, options string) FormField {
+	def := FormField{
+		Kind:     FieldSelect,
+		Name:     htmlAttr(attrs, "name"),
+		Required: htmlBoolAttr(attrs, "required"),
+	}
+
+	for _, option := range optionPattern.FindAllStringSubmatch(options, -1) {
+		value := htmlAttr(option[1], "value")
+		if value == "" && !strings.Contains(strings.ToLower(option[1]), "value") {
+			value = htmlText(option[2])
+		}
+
+		def.Values = append(def.Values, value)
+		if def.Default == "" || htmlBoolAttr(option[1], "selected") {
+			def.Default = value
+		}
+	}
+
+	return def
+}
+
+func parseTextField(attrs, value string) FormField {
+	def := FormField{
+		Kind:     FieldText,
+		Name:     htmlAttr(attrs, "name"),
+		Default:  value,
+		Required: htmlBoolAttr(attrs, "required"),
+	}
+
+	if maxLength, err := strconv.Atoi(htmlAttr(attrs, "maxlength")); err == nil && maxLength > 0 {
+		def.MaxLength = maxLength
+	}
+
+	return def
+}
+
+func parseInputField(attrs string) FormField {
+	name := htmlAttr(attrs, "name")
+	inputType := strings.ToLower(htmlAttr(attrs, "type"))
+	if inputType == "" {
+		inputType = "text"
+	}
+
+	if imageNamePattern.MatchString(name) && (inputType == "hidden" || inputType == "file") {
+		return FormField{
+			Kind:     FieldImage,
+			Name:     name,
+			Required: true,
+		}
+	}
+
+	switch inputType {
+	case "text", "search":
+		return parseTextField(attrs, htmlAttr(attrs, "value"))
+	case "checkbox":
+		checked := htmlAttr(attrs, "value")
+		if checked == "" {
+			checked = "on"
+		}
+
+		def := FormField{
+			Kind:   FieldCheckbox,
+			Name:   name,
+			Values: []string{checked, "off"},
+		}
+		if htmlBoolAttr(attrs, "checked") {
+			def.Default = checked
+		} else {
+			def.Default = "off"
+		}
+		return def
+	case "radio":
+		def := FormField{
+			Kind:     FieldSelect,
+			Name:     name,
+			Values:   []string{htmlAttr(attrs, "value")},
+			Required: htmlBoolAttr(attrs, "required"),
+		}
+		if htmlBoolAttr(attrs, "checked") {
+			def.Default = def.Values[0]
+		}
+		return def
+	case "hidden":
+		return FormField{
+			Kind:    FieldHidden,
+			Name:    name,
+			Default: htmlAttr(attrs, "value"),
+		}
+	}
+
+	// Buttons and other inputs that carry no user data are not part of the schema.
+	return FormField{}
+}
+
+// Lookup returns the field with the given name.
+func (s *Schema) Lookup(name string) (FormField, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return FormField{}, false
+}
+
+// Names returns the names of the schema fields in document order.
+func (s *Schema) Names() []string {
+	names := make([]string, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		names = append(names, field.Name)
+	}
+	return names
+}
+
+// Defaults returns the default values of the non-image fields that have one.
+func (s *Schema) Defaults() map[string]string {
+	defaults := make(map[string]string)
+	for _, field := range s.Fields {
+		if field.Kind != FieldImage && field.Default != "" {
+			defaults[field.Name] = field.Default
+		}
+	}
+	return defaults
+}
+
+// ImageInputs returns the image fields of the schema as effect inputs.
+func (s *Schema) ImageInputs() []Input {
+	var inputs []Input
+	for _, input := range s.Inputs() {
+		if input.Kind == InputImage {
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs
+}
+
+// Inputs returns the image and text fields of the schema as effect inputs.
+func (s *Schema) Inputs() []Input {
+	var inputs []Input
+	for _, field := range s.Fields {
+		switch field.Kind {
+		case FieldImage:
+			inputs = append(inputs, Input{Name: field.Name, Kind: InputImage})
+		case FieldText:
+			inputs = append(inputs, Input{
+				Name:      field.Name,
+				Kind:      InputText,
+				Optional:  !field.Required,
+				MaxLength: field.MaxLength,
+				Charset:   field.Charset,
+			})
+		}
+	}
+	return inputs
+}
+
+// effect returns an Effect with the given name that uses the schema defaults
+// and inputs and validates parameters against the schema.
+func (s *Schema) effect(name string) Effect {
+	return Effect{
+		Name:   name,
+		Path:   s.Path,
+		Params: s.Defaults(),
+		Inputs: s.Inputs(),
+		Schema: s,
+	}
+}
+
+// Validate checks the given form parameters against the schema.
+// Image fields are skipped, as their values are only known after uploading.
+//
+// It returns nil if the parameters are valid, or an error wrapping one
+// *ValidationError per offending field otherwise.
+func (s *Schema) Validate(params map[string]string) error {
+	var errs []error
+
+	var unknown []string
+	for name := range params {
+		if _, ok := s.Lookup(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, &ValidationError{Field: name, Reason: "not a field of effect " + s.Path})
+	}
+
+	for _, field := range s.Fields {
+		if field.Kind == FieldImage {
+			continue
+		}
+
+		value, ok := params[field.Name]
+		if !ok || value == "" {
+			if field.Required {
+				errs = append(errs, &ValidationError{Field: field.Name, Reason: "value is required"})
+			}
+			continue
+		}
+
+		switch field.Kind {
+		case FieldCheckbox, FieldSelect:
+			if !slices.Contains(field.Values, value) {
+				errs = append(errs, &ValidationError{
+					Field:  field.Name,
+					Reason: fmt.Sprintf("%q is not one of %s", value, strings.Join(field.Values, ", ")),
+				})
+			}
+		case FieldText:
+			if field.MaxLength > 0 && len([]rune(value)) > field.MaxLength {
+				errs = append(errs, &ValidationError{
+					Field:  field.Name,
+					Reason: fmt.Sprintf("length exceeds %d characters", field.MaxLength),
+				})
+			} else if err := field.Charset.validate(field.Name, value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// EffectSchema fetches the page of the effect at the given category path
+// and parses its form into a Schema.
+func (c *PhotoFuniaClient) EffectSchema(ctx context.Context, effectPath string) (*Schema, error) {
+	page, err := c.getPageWithContext(ctx, c.site()+"/categories/"+effectPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get effect page %s: %w", effectPath, err)
+	}
+
+	return ParseSchema(page)
+}
@@ -0,0 +1,213 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadSchemaFixture(t *testing.T, name string) *Schema {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "discover", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := ParseSchema(data)
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+	return schema
+}
+
+func TestParseSchema(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    *Schema
+	}{
+		{
+			fixture: "effect_fat_maker.html",
+			want: &Schema{
+				Path: "faces/fat_maker",
+				Fields: []FormField{
+					{Kind: FieldHidden, Name: "current-category", Default: "faces"},
+					{Kind: FieldImage, Name: "image", Required: true},
+					{Kind: FieldHidden, Name: "image:crop"},
+					{Kind: FieldSelect, Name: "size", Values: []string{"S", "M", "L", "XXXXXL"}, Default: "XXXXXL"},
+				},
+			},
+		},
+		{
+			fixture: "effect_clown.html",
+			want: &Schema{
+				Path: "faces/clown",
+				Fields: []FormField{
+					{Kind: FieldHidden, Name: "current-category", Default: "faces"},
+					{Kind: FieldImage, Name: "image", Required: true},
+					{Kind: FieldHidden, Name: "image:crop"},
+					{Kind: FieldCheckbox, Name: "hat", Values: []string{"on", "off"}, Default: "on"},
+				},
+			},
+		},
+		{
+			fixture: "effect_writing_on_sand.html",
+			want: &Schema{
+				Path: "lab/writing_on_sand",
+				Fields: []FormField{
+					{Kind: FieldHidden, Name: "current-category", Default: "lab"},
+					{Kind: FieldText, Name: "text", Required: true, MaxLength: 15},
+					{Kind: FieldText, Name: "text2", MaxLength: 20},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got := loadSchemaFixture(t, tt.fixture)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSchema() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSchemaRadio(t *testing.T) {
+	page := `<form action="/categories/faces/emotions?server=1" method="post">
+		<input type="radio" name="emotion" value="smile">
+		<input type="radio" name="emotion" value="sad" checked>
+		<input type="radio" name="emotion" value="angry">
+	</form>`
+
+	schema, err := ParseSchema([]byte(page))
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	want := []FormField{{Kind: FieldSelect, Name: "emotion", Values: []string{"smile", "sad", "angry"}, Default: "sad"}}
+	if !reflect.DeepEqual(schema.Fields, want) {
+		t.Errorf("ParseSchema() fields = %+v, want %+v", schema.Fields, want)
+	}
+}
+
+func TestParseSchemaCheckboxValue(t *testing.T) {
+	page := `<form action="/categories/faces/clown?server=1" method="post">
+		<input type="checkbox" name="hat" value="1" checked>
+	</form>`
+
+	schema, err := ParseSchema([]byte(page))
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	want := []FormField{{Kind: FieldCheckbox, Name: "hat", Values: []string{"1", "off"}, Default: "1"}}
+	if !reflect.DeepEqual(schema.Fields, want) {
+		t.Errorf("ParseSchema() fields = %+v, want %+v", schema.Fields, want)
+	}
+	if err := schema.Validate(map[string]string{"hat": "1"}); err != nil {
+		t.Errorf("Validate() error = %v, want the checked value accepted", err)
+	}
+}
+
+func TestParseSchemaNoForm(t *testing.T) {
+	if _, err := ParseSchema([]byte(`<html><form action="/search"><input name="q"></form></html>`)); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := loadSchemaFixture(t, "effect_writing_on_sand.html")
+	fatMaker := loadSchemaFixture(t, "effect_fat_maker.html")
+
+	tests := []struct {
+		name    string
+		schema  *Schema
+		params  map[string]string
+		invalid []string
+	}{
+		{
+			name:   "Valid select",
+			schema: fatMaker,
+			params: map[string]string{"current-category": "faces", "size": "M"},
+		},
+		{
+			name:    "Unknown select value",
+			schema:  fatMaker,
+			params:  map[string]string{"size": "XXL"},
+			invalid: []string{"size"},
+		},
+		{
+			name:    "Unknown field",
+			schema:  fatMaker,
+			params:  map[string]string{"colour": "red"},
+			invalid: []string{"colour"},
+		},
+		{
+			name:    "Missing required text",
+			schema:  schema,
+			params:  map[string]string{"text2": "hello"},
+			invalid: []string{"text"},
+		},
+		{
+			name:    "Text too long",
+			schema:  schema,
+			params:  map[string]string{"text": "this text is far too long"},
+			invalid: []string{"text"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Validate(tt.params)
+			if len(tt.invalid) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			for _, field := range tt.invalid {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), "field "+field) {
+					t.Errorf("Validate() error = %v, want error for field %s", err, field)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyEffectValidatesSchema(t *testing.T) {
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: &MockTransport{
+			RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+				t.Errorf("unexpected request to %s", req.URL)
+				return nil, errors.New("unexpected request")
+			},
+		}},
+	}
+
+	effect := DiscoveredEffect{
+		Path:   "faces/fat_maker",
+		Schema: loadSchemaFixture(t, "effect_fat_maker.html"),
+	}.Effect()
+
+	imageReader := io.NopCloser(bytes.NewReader([]byte("fake-image-data")))
+	_, err := client.ApplyEffect(context.Background(), effect, imageReader, map[string]string{"size": "XXL"})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("ApplyEffect() error = %v, want *ValidationError", err)
+	}
+	if validationErr.Field != "size" {
+		t.Errorf("ValidationError.Field = %q, want %q", validationErr.Field, "size")
+	}
+}
@@ -0,0 +1,123 @@
+package photofunia
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// sessionState holds the PHPSESSID of a client. It is safe for concurrent use,
+// and collapses concurrent requests for a new PHPSESSID into a single one.
+type sessionState struct {
+	mu sync.Mutex
+	id string
+
+	// pending is the request for a new PHPSESSID in flight, if any.
+	pending *sessionCall
+}
+
+// sessionCall is a request for a new PHPSESSID shared by concurrent callers.
+type sessionCall struct {
+	done chan struct{}
+	id   string
+	err  error
+}
+
+// sessionState returns the session state of the client. Clients built
+// without NewClient get theirs on first use.
+func (c *PhotoFuniaClient) sessionState() *sessionState {
+	if s := c.session.Load(); s != nil {
+		return s
+	}
+	c.session.CompareAndSwap(nil, &sessionState{})
+	return c.session.Load()
+}
+
+// SessionID returns the PHPSESSID the client currently uses, or an empty
+// string if it has not obtained one yet. It is safe for concurrent use.
+//
+// To make the client use a known PHPSESSID, use StaticSession.
+func (c *PhotoFuniaClient) SessionID() string {
+	return c.sessionState().current()
+}
+
+// current returns the PHPSESSID, or an empty string if there is none.
+func (s *sessionState) current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.id
+}
+
+// get returns the PHPSESSID, calling obtain to get one if there is none.
+// Concurrent callers wait for the first one's call to obtain and share its
+// outcome. When that call was canceled by its caller's context, the next
+// waiter makes a call of its own.
+func (s *sessionState) get(ctx context.Context, obtain func(context.Context) (string, error)) (string, error) {
+	for {
+		s.mu.Lock()
+		if s.id != "" {
+			id := s.id
+			s.mu.Unlock()
+			return id, nil
+		}
+
+		call := s.pending
+		if call == nil {
+			call = &sessionCall{done: make(chan struct{})}
+			s.pending = call
+			s.mu.Unlock()
+
+			call.id, call.err = obtain(ctx)
+
+			s.mu.Lock()
+			if call.err == nil {
+				s.id = call.id
+			}
+			s.pending = nil
+			s.mu.Unlock()
+			close(call.done)
+
+			return call.id, call.err
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+
+		canceled := errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)
+		if !canceled {
+			return call.id, call.err
+		}
+	}
+}
+
+// expire forgets the PHPSESSID if it still is the expired one.
+func (s *sessionState) expire(expired string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.id == expired {
+		s.id = ""
+	}
+}
+
+// sessionExpired reports whether PhotoFunia answered a request as if its
+// session were unknown, by redirecting it back to the cookie warning.
+func sessionExpired(resp *http.Response) bool {
+	return resp.Request != nil && resp.Request.URL != nil &&
+		strings.TrimSuffix(resp.Request.URL.Path, "/") == "/cookie-warning"
+}
+
+// refreshSession replaces an expired PHPSESSID with a new one, and returns the
+// PHPSESSID to use from now on. The session is left alone if it no longer is
+// the expired one, as when another stage has already replaced it.
+func (c *PhotoFuniaClient) refreshSession(ctx context.Context, expired string) (string, error) {
+	c.sessionState().expire(expired)
+	return c.sessionIDWithContext(ctx)
+}
@@ -0,0 +1,366 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// cookieWarningResponse answers a request the way PhotoFunia does when its
+// session has expired, as a redirect to the cookie warning.
+func cookieWarningResponse() (*http.Response, error) {
+	warningURL, _ := url.Parse("https://photofunia.com/cookie-warning")
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Request:    &http.Request{URL: warningURL},
+		Body:       http.NoBody,
+	}, nil
+}
+
+func TestSessionExpiredReplaysStage(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		match   string
+		failure func() (*http.Response, error)
+	}{
+		{
+			name:    "Apply redirected to cookie warning",
+			method:  "POST",
+			match:   "/categories/faces/fat_maker",
+			failure: cookieWarningResponse,
+		},
+		{
+			name:    "Download redirected to cookie warning",
+			method:  "GET",
+			match:   "example.com/result.jpg",
+			failure: cookieWarningResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, attempts := failingTransport(t, tt.method, tt.match, 1, tt.failure)
+			requests := countRequests(transport, "cookie-warning", "/images")
+			client := NewClient(WithTransport(transport))
+
+			data, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+			if err != nil {
+				t.Fatalf("ApplyEffect() error = %v", err)
+			}
+			if string(data) != "fake-image-data" {
+				t.Errorf("ApplyEffect() = %q, want %q", data, "fake-image-data")
+			}
+			if got := attempts(); got != 2 {
+				t.Errorf("attempts = %d, want 2", got)
+			}
+			if got := requests("cookie-warning"); got != 2 {
+				t.Errorf("session requests = %d, want 2", got)
+			}
+			if got := requests("/images"); got != 1 {
+				t.Errorf("uploads = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestSessionExpiredResultPageReappliesEffect(t *testing.T) {
+	transport, sessions := sessionTransport(t, "")
+
+	var mu sync.Mutex
+	var applies, resultPages []string
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		cookie := req.Header.Get("Cookie")
+		switch {
+		case req.Method == "POST" && strings.Contains(req.URL.String(), "/categories/"):
+			mu.Lock()
+			applies = append(applies, cookie)
+			mu.Unlock()
+		case req.Method == "GET" && strings.Contains(req.URL.String(), "/results/"):
+			mu.Lock()
+			resultPages = append(resultPages, cookie)
+			mu.Unlock()
+			if strings.HasSuffix(cookie, "PHPSESSID=session-1") {
+				return cookieWarningResponse()
+			}
+		}
+		return roundTrip(req)
+	}
+	client := NewClient(WithTransport(transport))
+
+	if _, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil); err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+	if got := sessions.Load(); got != 2 {
+		t.Errorf("session requests = %d, want 2", got)
+	}
+	if len(applies) != 2 || !strings.HasSuffix(applies[1], "PHPSESSID=session-2") {
+		t.Errorf("apply requests with cookies %q, want the effect applied again with the new session", applies)
+	}
+	if len(resultPages) != 2 || !strings.HasSuffix(resultPages[1], "PHPSESSID=session-2") {
+		t.Errorf("result page requests with cookies %q, want the page read again with the new session", resultPages)
+	}
+}
+
+func TestMissingResultPageReappliesEffect(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		wantErr  bool
+	}{
+		{name: "Missing once", failures: 1},
+		{name: "Still missing", failures: 10, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, resultPages := failingTransport(t, "GET", "/results/", tt.failures, statusResponse(http.StatusNotFound, nil))
+			requests := countRequests(transport, "cookie-warning", "/categories/")
+			client := NewClient(WithTransport(transport))
+
+			_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+			if !tt.wantErr && err != nil {
+				t.Fatalf("ApplyEffect() error = %v", err)
+			}
+			if tt.wantErr && (errors.Is(err, ErrSessionExpired) || !hasStatus(err, http.StatusNotFound)) {
+				t.Errorf("ApplyEffect() error = %v, want a result page error with status 404", err)
+			}
+
+			if got := resultPages(); got != 2 {
+				t.Errorf("result page requests = %d, want 2", got)
+			}
+			if got := requests("/categories/"); got != 2 {
+				t.Errorf("apply requests = %d, want 2", got)
+			}
+			if got := requests("cookie-warning"); got != 2 {
+				t.Errorf("session requests = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestResumeMissingResultPage(t *testing.T) {
+	transport, _ := failingTransport(t, "GET", "/results/", 10, statusResponse(http.StatusNotFound, nil))
+	requests := countRequests(transport, "cookie-warning", "/categories/")
+	client := NewClient(WithTransport(transport))
+
+	cp := &Checkpoint{Path: "faces/fat_maker", SessionID: "test-session-id", ResultURL: "https://photofunia.com/results/result123"}
+	_, err := client.Resume(context.Background(), cp, nil)
+
+	var stageErr *Error
+	if !errors.As(err, &stageErr) || stageErr.Stage != StageResultPage || stageErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Resume() error = %v, want result page *Error with status 404", err)
+	}
+	if got := requests("cookie-warning") + requests("/categories/"); got != 0 {
+		t.Errorf("session and apply requests = %d, want 0 for a result page applied earlier", got)
+	}
+}
+
+func TestSessionExpiredReplaysOnce(t *testing.T) {
+	transport, attempts := failingTransport(t, "POST", "/categories/faces/fat_maker", 10, cookieWarningResponse)
+	client := NewClient(WithTransport(transport), WithRetryPolicy(testRetryPolicy()))
+
+	_, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("ApplyEffect() error = %v, want ErrSessionExpired", err)
+	}
+
+	var stageErr *Error
+	if !errors.As(err, &stageErr) || stageErr.Stage != StageApply {
+		t.Errorf("error = %v, want apply *Error", err)
+	}
+	if got := attempts(); got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+}
+
+func TestSessionExpiredUsesSessionSource(t *testing.T) {
+	transport, _ := failingTransport(t, "POST", "/categories/faces/fat_maker", 1, cookieWarningResponse)
+
+	var sessions []string
+	source := SessionFunc(func(ctx context.Context) (string, error) {
+		sessions = append(sessions, "pooled-session")
+		return sessions[len(sessions)-1], nil
+	})
+	client := NewClient(WithTransport(transport), WithSessionSource(source))
+
+	if _, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil); err != nil {
+		t.Fatalf("ApplyEffect() error = %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Errorf("session source calls = %d, want 2", len(sessions))
+	}
+}
+
+func TestRefreshSessionKeepsNewerSession(t *testing.T) {
+	client := &PhotoFuniaClient{logger: &MockLogger{}, client: &http.Client{Transport: &MockTransport{}}}
+	client.session.Store(&sessionState{id: "newer-session"})
+
+	if _, err := client.refreshSession(context.Background(), "expired-session"); err != nil {
+		t.Fatalf("refreshSession() error = %v", err)
+	}
+	if client.SessionID() != "newer-session" {
+		t.Errorf("PHPSESSID = %q, want %q", client.SessionID(), "newer-session")
+	}
+}
+
+// sessionTransport wraps newEffectTransport, handing out numbered sessions
+// slowly enough for concurrent requests to overlap, and redirecting apply
+// requests made with an expired session to the cookie warning.
+func sessionTransport(t *testing.T, expired string) (*MockTransport, *atomic.Int32) {
+	t.Helper()
+
+	var sessions atomic.Int32
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		switch {
+		case strings.Contains(req.URL.String(), "cookie-warning"):
+			n := sessions.Add(1)
+			time.Sleep(20 * time.Millisecond)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Set-Cookie": []string{fmt.Sprintf("PHPSESSID=session-%d", n)}},
+				Body:       http.NoBody,
+			}, nil
+		case req.Method == "POST" && strings.Contains(req.URL.String(), "/categories/") &&
+			strings.HasSuffix(req.Header.Get("Cookie"), "PHPSESSID="+expired):
+			io.Copy(io.Discard, req.Body)
+			return cookieWarningResponse()
+		}
+		return roundTrip(req)
+	}
+	return transport, &sessions
+}
+
+// applyConcurrently applies FatMaker with n goroutines sharing the client.
+func applyConcurrently(t *testing.T, client *PhotoFuniaClient, n int) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			data, err := client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(bytes.NewReader([]byte("fake-image-data"))), nil)
+			if err != nil {
+				t.Errorf("ApplyEffect() error = %v", err)
+				return
+			}
+			if string(data) != "fake-image-data" {
+				t.Errorf("ApplyEffect() = %q, want %q", data, "fake-image-data")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentSessionBootstrap(t *testing.T) {
+	transport, sessions := sessionTransport(t, "")
+	client := NewClient(WithTransport(transport))
+
+	applyConcurrently(t, client, 16)
+
+	if got := sessions.Load(); got != 1 {
+		t.Errorf("session requests = %d, want 1", got)
+	}
+	if got := client.SessionID(); got != "session-1" {
+		t.Errorf("SessionID() = %q, want %q", got, "session-1")
+	}
+}
+
+func TestConcurrentSessionRefresh(t *testing.T) {
+	transport, sessions := sessionTransport(t, "expired-session")
+	client := NewClient(WithTransport(transport), withSessionID("expired-session"))
+
+	applyConcurrently(t, client, 16)
+
+	if got := sessions.Load(); got != 1 {
+		t.Errorf("session requests = %d, want 1", got)
+	}
+	if got := client.SessionID(); got != "session-1" {
+		t.Errorf("SessionID() = %q, want %q", got, "session-1")
+	}
+}
+
+func TestConcurrentSessionBootstrapFailure(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	obtain := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "", errors.New("no session")
+	}
+
+	s := &sessionState{}
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := s.get(context.Background(), obtain)
+			errs <- err
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err == nil {
+			t.Error("get() succeeded, want the error of the shared request")
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+	if got := s.current(); got != "" {
+		t.Errorf("current() = %q after a failed request, want empty", got)
+	}
+}
+
+func TestSessionBootstrapCanceledByFirstCaller(t *testing.T) {
+	s := &sessionState{}
+	started := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := s.get(ctx, func(ctx context.Context) (string, error) {
+			close(started)
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+		firstErr <- err
+	}()
+	<-started
+
+	secondID := make(chan string, 1)
+	go func() {
+		id, err := s.get(context.Background(), func(ctx context.Context) (string, error) {
+			return "second-session", nil
+		})
+		if err != nil {
+			t.Errorf("get() error = %v", err)
+		}
+		secondID <- id
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("first get() error = %v, want context.Canceled", err)
+	}
+	if id := <-secondID; id != "second-session" {
+		t.Errorf("second get() = %q, want %q", id, "second-session")
+	}
+}
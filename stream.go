@@ -0,0 +1,156 @@
+package photofunia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// ErrResultTooLarge is returned when a result image exceeds the maximum size
+// set with WithMaxResultSize.
+var ErrResultTooLarge = errors.New("result image exceeds the maximum size")
+
+// limitedBody wraps a response body, failing with ErrResultTooLarge once
+// more than the remaining number of bytes has been read.
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrResultTooLarge
+	}
+
+	// Read one byte past the limit to tell an exact fit from an overflow.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.body.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrResultTooLarge
+	}
+	return n, err
+}
+
+func (l *limitedBody) Close() error {
+	return l.body.Close()
+}
+
+// ResultStream is a result image being downloaded. It reads the image
+// directly from the server response without buffering it in memory.
+//
+// A ResultStream must be closed when done.
+type ResultStream struct {
+	// Result holds the metadata of the run. Its Data is nil, its MIMEType is
+	// the Content-Type sent by the server and its Size is the Content-Length,
+	// or -1 if unknown. Dimensions are not decoded.
+	Result *Result
+
+	body io.ReadCloser
+}
+
+// Read reads the image data.
+func (s *ResultStream) Read(p []byte) (int, error) {
+	return s.body.Read(p)
+}
+
+// WriteTo writes the remaining image data to w.
+// It implements io.WriterTo, so that io.Copy uses it directly.
+func (s *ResultStream) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, s.body)
+}
+
+// Close closes the underlying response body.
+func (s *ResultStream) Close() error {
+	return s.body.Close()
+}
+
+// ApplyEffectStream is like ApplyEffectResult, but returns the result image as
+// a stream instead of reading it into memory. The caller must close the stream.
+func (c *PhotoFuniaClient) ApplyEffectStream(ctx context.Context, effect Effect, input io.ReadCloser, overrides map[string]string) (*ResultStream, error) {
+	inputs, err := effect.singleImageInputs(input)
+	if err != nil {
+		return nil, err
+	}
+	return c.ApplyEffectInputsStream(ctx, effect, inputs, overrides)
+}
+
+// ApplyEffectInputsStream is like ApplyEffectInputsResult, but returns the
+// result image as a stream instead of reading it into memory. The caller must
+// close the stream.
+func (c *PhotoFuniaClient) ApplyEffectInputsStream(ctx context.Context, effect Effect, inputs EffectInputs, overrides map[string]string) (*ResultStream, error) {
+	params, imageFields, name, err := effect.prepareRun(inputs, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := newCheckpoint(effect.Path, params, imageFields, name)
+	result, err := c.prepareResultWithContext(ctx, cp, inputs.Images)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var resp *http.Response
+	err = c.withRetry(ctx, StageDownload, cp, func() error {
+		var err error
+		resp, err = c.openImageWithContext(ctx, result.ImageURL, result.ResultURL)
+		return err
+	})
+	if err != nil {
+		return nil, cp.fail(err)
+	}
+	result.Timings.Download = time.Since(start)
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		result.MIMEType = mediaType
+	}
+	result.Size = resp.ContentLength
+
+	return &ResultStream{Result: result, body: resp.Body}, nil
+}
+
+// ApplyEffectTo is like ApplyEffectResult, but writes the result image to w
+// as it is downloaded instead of reading it into memory.
+//
+// The returned Result holds the metadata of the run, with Data left nil and
+// Size set to the number of bytes written.
+func (c *PhotoFuniaClient) ApplyEffectTo(ctx context.Context, w io.Writer, effect Effect, input io.ReadCloser, overrides map[string]string) (*Result, error) {
+	inputs, err := effect.singleImageInputs(input)
+	if err != nil {
+		return nil, err
+	}
+	return c.ApplyEffectInputsTo(ctx, w, effect, inputs, overrides)
+}
+
+// ApplyEffectInputsTo is like ApplyEffectInputsResult, but writes the result
+// image to w as it is downloaded instead of reading it into memory.
+//
+// The returned Result holds the metadata of the run, with Data left nil and
+// Size set to the number of bytes written.
+func (c *PhotoFuniaClient) ApplyEffectInputsTo(ctx context.Context, w io.Writer, effect Effect, inputs EffectInputs, overrides map[string]string) (*Result, error) {
+	stream, err := c.ApplyEffectInputsStream(ctx, effect, inputs, overrides)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	start := time.Now()
+	written, err := stream.WriteTo(w)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write image data: %w", err)
+	}
+
+	result := stream.Result
+	result.Timings.Download += time.Since(start)
+	result.Size = written
+
+	c.logger.Info("successfully streamed image", Field{"url", result.ImageURL}, Field{"size", written})
+	return result, nil
+}
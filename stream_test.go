@@ -0,0 +1,122 @@
+package photofunia
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newStreamTransport returns a transport serving imageData as the result image
+// with the given Content-Length, which may be -1 if unknown.
+func newStreamTransport(t *testing.T, imageData []byte, contentLength int64) *MockTransport {
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "example.com/result.jpg") {
+			return &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Type": []string{"image/jpeg"}},
+				ContentLength: contentLength,
+				Body:          io.NopCloser(bytes.NewReader(imageData)),
+			}, nil
+		}
+		return roundTrip(req)
+	}
+	return transport
+}
+
+func TestApplyEffectStream(t *testing.T) {
+	imageData := []byte("streamed-image-data")
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: newStreamTransport(t, imageData, int64(len(imageData)))},
+	}
+
+	stream, err := client.ApplyEffectStream(context.Background(), FatMaker, io.NopCloser(strings.NewReader("img")), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffectStream() error = %v", err)
+	}
+	defer stream.Close()
+
+	if stream.Result.MIMEType != "image/jpeg" || stream.Result.Size != int64(len(imageData)) {
+		t.Errorf("stream metadata = %q, %d", stream.Result.MIMEType, stream.Result.Size)
+	}
+	if stream.Result.ResultURL == "" || stream.Result.ImageKey != "test-image-key" {
+		t.Errorf("stream result = %+v", stream.Result)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, stream); err != nil {
+		t.Fatalf("io.Copy() error = %v", err)
+	}
+	if buf.String() != string(imageData) {
+		t.Errorf("streamed data = %q, want %q", buf.String(), imageData)
+	}
+}
+
+func TestApplyEffectTo(t *testing.T) {
+	imageData := []byte("streamed-image-data")
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: newStreamTransport(t, imageData, -1)},
+	}
+
+	var buf bytes.Buffer
+	result, err := client.ApplyEffectTo(context.Background(), &buf, FatMaker, io.NopCloser(strings.NewReader("img")), nil)
+	if err != nil {
+		t.Fatalf("ApplyEffectTo() error = %v", err)
+	}
+
+	if buf.String() != string(imageData) {
+		t.Errorf("written data = %q, want %q", buf.String(), imageData)
+	}
+	if result.Size != int64(len(imageData)) || result.Data != nil {
+		t.Errorf("result size = %d, data = %q", result.Size, result.Data)
+	}
+}
+
+func TestMaxResultSize(t *testing.T) {
+	imageData := []byte("0123456789")
+
+	tests := []struct {
+		name          string
+		maxSize       int64
+		contentLength int64
+		wantErr       bool
+	}{
+		{name: "Exact fit", maxSize: 10, contentLength: -1},
+		{name: "Known length too large", maxSize: 5, contentLength: 10, wantErr: true},
+		{name: "Unknown length too large", maxSize: 5, contentLength: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := (&PhotoFuniaClient{
+				logger: &MockLogger{},
+				client: &http.Client{Transport: newStreamTransport(t, imageData, tt.contentLength)},
+			}).WithMaxResultSize(tt.maxSize)
+
+			var buf bytes.Buffer
+			_, err := client.ApplyEffectTo(context.Background(), &buf, FatMaker, io.NopCloser(strings.NewReader("img")), nil)
+			if tt.wantErr {
+				if !errors.Is(err, ErrResultTooLarge) {
+					t.Errorf("ApplyEffectTo() error = %v, want ErrResultTooLarge", err)
+				}
+				if int64(buf.Len()) > tt.maxSize {
+					t.Errorf("wrote %d bytes past the limit of %d", buf.Len(), tt.maxSize)
+				}
+			} else if err != nil {
+				t.Errorf("ApplyEffectTo() error = %v", err)
+			}
+
+			_, err = client.ApplyEffect(context.Background(), FatMaker, io.NopCloser(strings.NewReader("img")), nil)
+			if tt.wantErr != errors.Is(err, ErrResultTooLarge) {
+				t.Errorf("ApplyEffect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package photofunia
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// UploadedImage is an image uploaded to PhotoFunia with Upload.
+// Its key can be reused to apply any number of effects without uploading
+// the image again, until the image expires.
+type UploadedImage struct {
+	// Key identifies the uploaded image on the server.
+	Key string
+
+	// Server is the number of the server that stores the image.
+	Server int
+
+	// Expiry is the time the server discards the image, or the zero time if unknown.
+	Expiry time.Time
+
+	// Width is the width of the stored image in pixels.
+	Width int
+
+	// Height is the height of the stored image in pixels.
+	Height int
+
+	// Result is the full upload response returned by the server.
+	Result *UploadResult
+}
+
+// Expired reports whether the server has discarded the image.
+// It returns false if the expiry time is unknown.
+func (u *UploadedImage) Expired() bool {
+	return !u.Expiry.IsZero() && !time.Now().Before(u.Expiry)
+}
+
+// Upload uploads the provided image to PhotoFunia with context support.
+// The returned image can be passed to ApplyToUploaded any number of times.
+//
+// The ctx parameter allows for cancellation and timeout control.
+// The img parameter should be an io.ReadCloser containing the image data.
+// The function will close the reader when done.
+func (c *PhotoFuniaClient) Upload(ctx context.Context, img io.ReadCloser) (*UploadedImage, error) {
+	result, err := c.uploadImageWithRetry(ctx, img)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Key == "" {
+		return nil, &Error{Stage: StageUpload, StatusCode: http.StatusOK, Err: ErrEmptyImageKey}
+	}
+
+	uploaded := &UploadedImage{
+		Key:    result.Key,
+		Server: result.Server,
+		Expiry: result.ExpiryTime(),
+		Width:  result.Image.Highres.Width,
+		Height: result.Image.Highres.Height,
+		Result: result,
+	}
+
+	c.logger.Info("uploaded image", Field{"key", uploaded.Key}, Field{"expiry", uploaded.Expiry})
+	return uploaded, nil
+}
+
+// ApplyToUploaded applies the given effect to an image uploaded earlier with Upload.
+// It returns the processed image data as a byte slice.
+//
+// The effect must take exactly one image. The overrides parameter replaces or
+// adds form parameters on top of the effect's defaults. It may be nil.
+func (c *PhotoFuniaClient) ApplyToUploaded(ctx context.Context, uploaded *UploadedImage, effect Effect, overrides map[string]string) ([]byte, error) {
+	result, err := c.ApplyToUploadedResult(ctx, uploaded, effect, overrides)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// ApplyToUploadedResult is like ApplyToUploaded, but returns the processed
+// image along with metadata about the run.
+func (c *PhotoFuniaClient) ApplyToUploadedResult(ctx context.Context, uploaded *UploadedImage, effect Effect, overrides map[string]string) (*Result, error) {
+	images := effect.imageInputs()
+	if len(images) != 1 {
+		return nil, fmt.Errorf("effect %s does not take exactly one image input", effect.Path)
+	}
+
+	inputs := EffectInputs{Uploaded: map[string]*UploadedImage{images[0].Name: uploaded}}
+	return c.ApplyEffectInputsResult(ctx, effect, inputs, overrides)
+}
+
+// sizedImage is an image reader whose size is known in advance.
+type sizedImage struct {
+	io.ReadCloser
+	size int64
+}
+
+// WithContentLength wraps an image reader whose size in bytes is known, so
+// that its upload is sent with a Content-Length header instead of chunked.
+// The reader must yield exactly size bytes.
+//
+// Files opened with os.Open and readers with a Len method, such as
+// bytes.Reader, are sized automatically and do not need wrapping.
+func WithContentLength(img io.ReadCloser, size int64) io.ReadCloser {
+	return &sizedImage{ReadCloser: img, size: size}
+}
+
+// imageSize returns the number of bytes left to read from an image reader,
+// if it can be determined without reading it.
+func imageSize(img io.Reader) (int64, bool) {
+	switch r := img.(type) {
+	case *sizedImage:
+		return r.size, true
+	case rewoundImage:
+		return imageSize(r.ReadCloser)
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		return info.Size() - offset, true
+	}
+	return 0, false
+}
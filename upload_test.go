@@ -0,0 +1,241 @@
+package photofunia
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadAndApplyToUploaded(t *testing.T) {
+	var forms []map[string]string
+	transport := newEffectTransport(t, "faces/", func(form map[string]string) {
+		forms = append(forms, form)
+	})
+
+	uploads := 0
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "/images") {
+			uploads++
+			jsonResponse := `{"response":{"key":"uploaded-key","server":3,"expiry":4102444800,"lifetime":3600,"image":{"highres":{"url":"","width":961,"height":1093}}}}`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(jsonResponse)),
+			}, nil
+		}
+		return roundTrip(req)
+	}
+
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: transport},
+	}
+
+	uploaded, err := client.Upload(context.Background(), io.NopCloser(strings.NewReader("fake-image-data")))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	want := UploadedImage{
+		Key:    "uploaded-key",
+		Server: 3,
+		Expiry: time.Unix(4102444800, 0),
+		Width:  961,
+		Height: 1093,
+	}
+	if uploaded.Result == nil {
+		t.Fatal("Upload() returned no upload result")
+	}
+	if uploaded.Result.LifetimeDuration() != time.Hour {
+		t.Errorf("Result.LifetimeDuration() = %v, want %v", uploaded.Result.LifetimeDuration(), time.Hour)
+	}
+
+	got := *uploaded
+	got.Result = nil
+	if got != want {
+		t.Errorf("Upload() = %+v, want %+v", got, want)
+	}
+
+	for _, effect := range []Effect{FatMaker, Clown, Zombie} {
+		result, err := client.ApplyToUploaded(context.Background(), uploaded, effect, nil)
+		if err != nil {
+			t.Fatalf("ApplyToUploaded(%s) error = %v", effect.Path, err)
+		}
+		if string(result) != "fake-image-data" {
+			t.Errorf("ApplyToUploaded(%s) = %v, want %v", effect.Path, string(result), "fake-image-data")
+		}
+	}
+
+	if uploads != 1 {
+		t.Errorf("uploads = %d, want 1", uploads)
+	}
+	for _, form := range forms {
+		if form["image"] != "uploaded-key" {
+			t.Errorf("image field = %q, want %q", form["image"], "uploaded-key")
+		}
+	}
+}
+
+func TestApplyToUploadedInvalid(t *testing.T) {
+	client := NewPhotoFuniaClient()
+
+	tests := []struct {
+		name     string
+		uploaded *UploadedImage
+		effect   Effect
+	}{
+		{
+			name:     "Expired image",
+			uploaded: &UploadedImage{Key: "key", Expiry: time.Now().Add(-time.Minute)},
+			effect:   FatMaker,
+		},
+		{
+			name:     "Missing key",
+			uploaded: &UploadedImage{},
+			effect:   FatMaker,
+		},
+		{
+			name:     "Nil image",
+			uploaded: nil,
+			effect:   FatMaker,
+		},
+		{
+			name:     "Image-less effect",
+			uploaded: &UploadedImage{Key: "key"},
+			effect:   Effect{Path: "lab/writing_on_sand", Inputs: []Input{{Name: "text", Kind: InputText}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.ApplyToUploaded(context.Background(), tt.uploaded, tt.effect, nil); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestUploadResultTimes(t *testing.T) {
+	result := UploadResult{Expiry: 1700003600, Created: 1700000000, Lifetime: 3600}
+
+	if got, want := result.ExpiryTime(), time.Unix(1700003600, 0); !got.Equal(want) {
+		t.Errorf("ExpiryTime() = %v, want %v", got, want)
+	}
+	if got, want := result.CreatedTime(), time.Unix(1700000000, 0); !got.Equal(want) {
+		t.Errorf("CreatedTime() = %v, want %v", got, want)
+	}
+	if got := result.LifetimeDuration(); got != time.Hour {
+		t.Errorf("LifetimeDuration() = %v, want %v", got, time.Hour)
+	}
+
+	var empty UploadResult
+	if !empty.ExpiryTime().IsZero() || !empty.CreatedTime().IsZero() {
+		t.Error("unset times should convert to the zero time")
+	}
+}
+
+func TestUploadStreamsBody(t *testing.T) {
+	imageData := strings.Repeat("fake-image-data", 1000)
+
+	file, err := os.CreateTemp(t.TempDir(), "image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(imageData); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		img               io.ReadCloser
+		wantContentLength bool
+	}{
+		{
+			name:              "Unknown size",
+			img:               io.NopCloser(strings.NewReader(imageData)),
+			wantContentLength: false,
+		},
+		{
+			name:              "Declared size",
+			img:               WithContentLength(io.NopCloser(strings.NewReader(imageData)), int64(len(imageData))),
+			wantContentLength: true,
+		},
+		{
+			name:              "File",
+			img:               file,
+			wantContentLength: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newEffectTransport(t, "faces/fat_maker", nil)
+			roundTrip := transport.RoundTripFunc
+			transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+				if strings.Contains(req.URL.String(), "/images") {
+					body, err := io.ReadAll(req.Body)
+					if err != nil {
+						t.Fatalf("failed to read upload body: %v", err)
+					}
+
+					if tt.wantContentLength && req.ContentLength != int64(len(body)) {
+						t.Errorf("ContentLength = %d, body length = %d", req.ContentLength, len(body))
+					}
+					if !tt.wantContentLength && req.ContentLength > 0 {
+						t.Errorf("ContentLength = %d, want unknown", req.ContentLength)
+					}
+					if !strings.Contains(string(body), imageData) {
+						t.Error("upload body does not contain the image data")
+					}
+				}
+				return roundTrip(req)
+			}
+
+			client := &PhotoFuniaClient{
+				logger: &MockLogger{},
+				client: &http.Client{Transport: transport},
+			}
+
+			if _, err := client.Upload(context.Background(), tt.img); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+		})
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("disk error")
+}
+
+func TestUploadReadError(t *testing.T) {
+	transport := newEffectTransport(t, "faces/fat_maker", nil)
+	roundTrip := transport.RoundTripFunc
+	transport.RoundTripFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), "/images") {
+			if _, err := io.ReadAll(req.Body); err != nil {
+				return nil, err
+			}
+		}
+		return roundTrip(req)
+	}
+
+	client := &PhotoFuniaClient{
+		logger: &MockLogger{},
+		client: &http.Client{Transport: transport},
+	}
+
+	_, err := client.Upload(context.Background(), io.NopCloser(failingReader{}))
+	if err == nil || !strings.Contains(err.Error(), "disk error") {
+		t.Errorf("Upload() error = %v, want disk error", err)
+	}
+}